@@ -22,6 +22,7 @@ func addStore(parent *cobra.Command) {
 
 	cmd.AddCommand(
 		addStoreSync(),
+		addStoreValidate(),
 		addStoreExtract(),
 		addStoreLoad(),
 		addStoreSave(),
@@ -82,6 +83,24 @@ func addStoreSync() *cobra.Command {
 	return cmd
 }
 
+func addStoreValidate() *cobra.Command {
+	o := &store.ValidateOpts{RootOpts: rootStoreOpts}
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate content files against the content and collection types",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			return store.ValidateCmd(ctx, o)
+		},
+	}
+	o.AddFlags(cmd)
+
+	return cmd
+}
+
 func addStoreLoad() *cobra.Command {
 	o := &store.LoadOpts{RootOpts: rootStoreOpts}
 
@@ -212,6 +231,7 @@ func addStoreAdd() *cobra.Command {
 		addStoreAddFile(),
 		addStoreAddImage(),
 		addStoreAddChart(),
+		addStoreAddGit(),
 	)
 
 	return cmd
@@ -240,6 +260,36 @@ func addStoreAddFile() *cobra.Command {
 	return cmd
 }
 
+func addStoreAddGit() *cobra.Command {
+	o := &store.AddGitOpts{RootOpts: rootStoreOpts}
+
+	cmd := &cobra.Command{
+		Use:   "git",
+		Short: "Add a git repository to the content store",
+		Example: `
+# add every branch and tag of a remote repository
+hauler store add git https://github.com/rancherfederal/hauler.git
+
+# add a branch and a tag of a local repository
+hauler store add git file:///path/to/repo --ref main --ref v1.0.0
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			s, err := o.Store(ctx)
+			if err != nil {
+				return err
+			}
+
+			return store.AddGitCmd(ctx, o, s, args[0])
+		},
+	}
+	o.AddFlags(cmd)
+
+	return cmd
+}
+
 func addStoreAddImage() *cobra.Command {
 	o := &store.AddImageOpts{RootOpts: rootStoreOpts}
 
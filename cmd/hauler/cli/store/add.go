@@ -2,101 +2,289 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/rancherfederal/ocil/pkg/artifacts/file/getter"
 	"github.com/spf13/cobra"
 	"helm.sh/helm/v3/pkg/action"
+	"k8s.io/client-go/util/homedir"
 
+	"github.com/rancherfederal/ocil/pkg/artifacts"
 	"github.com/rancherfederal/ocil/pkg/artifacts/file"
 	"github.com/rancherfederal/ocil/pkg/artifacts/image"
 
 	"github.com/rancherfederal/ocil/pkg/store"
 
+	"github.com/rancherfederal/hauler/internal/layout"
 	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/auth"
+	"github.com/rancherfederal/hauler/pkg/content"
 	"github.com/rancherfederal/hauler/pkg/content/chart"
+	"github.com/rancherfederal/hauler/pkg/lock"
 	"github.com/rancherfederal/hauler/pkg/log"
 	"github.com/rancherfederal/hauler/pkg/reference"
+	"github.com/rancherfederal/hauler/pkg/signature"
 )
 
 type AddFileOpts struct {
 	*RootOpts
-	Name string
+	Name        string
+	SHA256      string
+	ChecksumURL string
 }
 
 func (o *AddFileOpts) AddFlags(cmd *cobra.Command) {
 	f := cmd.Flags()
 	f.StringVarP(&o.Name, "name", "n", "", "(Optional) Name to assign to file in store")
+	f.StringVar(&o.SHA256, "sha256", "", "(Optional) sha256 checksum the file contents must match")
+	f.StringVar(&o.ChecksumURL, "checksum-url", "", "(Optional) Local or remote path to a checksum file (e.g. SHA256SUMS) holding the checksum the file contents must match")
 }
 
 func AddFileCmd(ctx context.Context, o *AddFileOpts, s *store.Layout, reference string) error {
 	cfg := v1alpha1.File{
-		Path: reference,
+		Path:        reference,
+		Name:        o.Name,
+		SHA256:      o.SHA256,
+		ChecksumURL: o.ChecksumURL,
 	}
 
-	return storeFile(ctx, s, cfg)
+	_, err := storeFile(ctx, s, cfg, nil)
+	return err
 }
 
-func storeFile(ctx context.Context, s *store.Layout, fi v1alpha1.File) error {
-	l := log.FromContext(ctx)
+func storeFile(ctx context.Context, s *store.Layout, fi v1alpha1.File, lck *lock.Lock) (ocispec.Descriptor, error) {
+	it, err := fileEntry(ctx, fi)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return storeEntry(ctx, s, it, lck)
+}
+
+func fileEntry(ctx context.Context, fi v1alpha1.File) (entry, error) {
+	checksum, err := fileChecksum(ctx, fi)
+	if err != nil {
+		return entry{}, err
+	}
+
+	if content.IsDirectory(fi.Path) {
+		if checksum != "" {
+			return entry{}, fmt.Errorf("file %s: checksums can't be verified for directories", fi.Path)
+		}
+
+		d := content.NewDirectory(fi.Path, fi.Name)
+		ref, err := reference.NewTagged(d.Name(), reference.DefaultTag)
+		if err != nil {
+			return entry{}, err
+		}
+		return entry{ref: ref.Name(), oci: d}, nil
+	}
 
 	copts := getter.ClientOptions{
 		NameOverride: fi.Name,
 	}
 
-	f := file.NewFile(fi.Path, file.WithClient(getter.NewClient(copts)))
+	fopts := []file.Option{file.WithClient(getter.NewClient(copts))}
+	if checksum != "" {
+		fopts = append(fopts, file.WithAnnotations(map[string]string{content.ChecksumAnnotation: checksum}))
+	}
+
+	f := file.NewFile(fi.Path, fopts...)
 	ref, err := reference.NewTagged(f.Name(fi.Path), reference.DefaultTag)
 	if err != nil {
-		return err
+		return entry{}, err
+	}
+
+	return entry{ref: ref.Name(), oci: f, sha256: checksum}, nil
+}
+
+// fileChecksum returns the sha256 checksum a file's contents must match, given directly or by a checksum file.  When
+// both are given they must agree.
+func fileChecksum(ctx context.Context, fi v1alpha1.File) (string, error) {
+	var checksum string
+	if fi.SHA256 != "" {
+		c, err := content.ParseSHA256(fi.SHA256)
+		if err != nil {
+			return "", fmt.Errorf("file %s: %w", fi.Path, err)
+		}
+		checksum = c
+	}
+
+	if fi.ChecksumURL != "" {
+		c, err := content.FetchChecksum(ctx, fi.ChecksumURL, content.ChecksumName(fi.Path))
+		if err != nil {
+			return "", fmt.Errorf("file %s: %w", fi.Path, err)
+		}
+		if checksum != "" && checksum != c {
+			return "", fmt.Errorf("file %s: sha256 %s does not match %s from %s", fi.Path, checksum, c, fi.ChecksumURL)
+		}
+		checksum = c
 	}
+	return checksum, nil
+}
 
-	desc, err := s.AddOCI(ctx, f, ref.Name())
+// verifyChecksum verifies the digest a file was described with matches the sha256 checksum, the contents written to the
+// store are verified against the same digest when they are fetched again
+func verifyChecksum(f *file.File, checksum string) error {
+	layers, err := f.Layers()
 	if err != nil {
 		return err
 	}
 
-	l.Infof("added 'file' to store at [%s], with digest [%s]", ref.Name(), desc.Digest.String())
+	d, err := layers[0].Digest()
+	if err != nil {
+		return err
+	}
+	if d.Hex != checksum {
+		return fmt.Errorf("file %s: sha256 %s does not match the expected checksum %s", f.Path, d.Hex, checksum)
+	}
 	return nil
 }
 
-type AddImageOpts struct {
+type AddGitOpts struct {
 	*RootOpts
 	Name string
+	Refs []string
+}
+
+func (o *AddGitOpts) AddFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringVarP(&o.Name, "name", "n", "", "(Optional) Name to assign to the repository in store")
+	f.StringSliceVar(&o.Refs, "ref", []string{}, "(Optional) Branches and tags to store, defaults to every branch and tag")
+}
+
+func AddGitCmd(ctx context.Context, o *AddGitOpts, s *store.Layout, url string) error {
+	cfg := v1alpha1.Git{
+		URL:  url,
+		Name: o.Name,
+		Refs: o.Refs,
+	}
+
+	it, err := gitEntry(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = storeEntry(ctx, s, it, nil)
+	return err
+}
+
+func gitEntry(g v1alpha1.Git) (entry, error) {
+	repo := content.NewGit(g.URL, g.Name, g.Refs)
+	ref, err := reference.NewTagged(repo.Name(), reference.DefaultTag)
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{ref: ref.Name(), oci: repo}, nil
+}
+
+type AddImageOpts struct {
+	*RootOpts
+	Name      string
+	Platforms []string
 }
 
 func (o *AddImageOpts) AddFlags(cmd *cobra.Command) {
 	f := cmd.Flags()
-	_ = f
+
+	f.StringSliceVar(&o.Platforms, "platform", []string{}, "Only store the given platforms (os/arch[/variant]) of a multi-platform image")
 }
 
 func AddImageCmd(ctx context.Context, o *AddImageOpts, s *store.Layout, reference string) error {
 	cfg := v1alpha1.Image{
-		Name: reference,
+		Name:      reference,
+		Platforms: o.Platforms,
 	}
 
-	return storeImage(ctx, s, cfg)
+	_, err := storeImage(ctx, s, cfg, nil, remote.WithAuthFromKeychain(o.Keychain()))
+	return err
 }
 
-func storeImage(ctx context.Context, s *store.Layout, i v1alpha1.Image) error {
-	l := log.FromContext(ctx)
+func storeImage(ctx context.Context, s *store.Layout, i v1alpha1.Image, lck *lock.Lock, ropts ...remote.Option) (ocispec.Descriptor, error) {
+	it, err := imageEntry(i, nil, ropts...)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
 
-	img, err := image.NewImage(i.Name)
+	entries, err := finalizeEntry(it, lck, ropts...)
 	if err != nil {
-		return err
+		return ocispec.Descriptor{}, err
+	}
+
+	var desc ocispec.Descriptor
+	for i, it := range entries {
+		d, err := storeEntry(ctx, s, it, lck)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		if i == 0 {
+			desc = d
+		}
+	}
+	return desc, nil
+}
+
+// imageEntry returns the entry of an image, restricted to its own platforms or otherwise the default platforms.  Images
+// that are verified are stored complete, as their signatures are for the complete image and would never verify the
+// restricted index in the store.
+func imageEntry(i v1alpha1.Image, platforms []gv1.Platform, ropts ...remote.Option) (entry, error) {
+	if i.Verify != nil {
+		if len(i.Platforms) > 0 {
+			return entry{}, fmt.Errorf("image %s: platforms can't be combined with verify, signatures are for the complete image", i.Name)
+		}
+		platforms = nil
+	}
+
+	if len(i.Platforms) > 0 {
+		ps, err := content.ParsePlatforms(i.Platforms)
+		if err != nil {
+			return entry{}, err
+		}
+		platforms = ps
 	}
 
 	r, err := name.ParseReference(i.Name)
 	if err != nil {
-		return err
+		return entry{}, err
 	}
 
-	desc, err := s.AddOCI(ctx, img, r.Name())
+	it, err := newImageEntry(r.Name(), i.Name, platforms, ropts...)
 	if err != nil {
-		return err
+		return entry{}, err
 	}
+	it.verify = i.Verify
+	return it, nil
+}
 
-	l.Infof("added 'image' to store at [%s], with digest [%s]", r.Name(), desc.Digest.String())
-	return nil
+// newImageEntry returns an entry for the image named name at ref, restricted to platforms when any are given
+func newImageEntry(ref string, name string, platforms []gv1.Platform, ropts ...remote.Option) (entry, error) {
+	if len(platforms) == 0 {
+		img, err := content.NewImage(name, ropts...)
+		if err != nil {
+			return entry{}, err
+		}
+		return entry{ref: ref, oci: img}, nil
+	}
+
+	img, idx, err := content.NewPlatformImage(name, platforms, ropts...)
+	if err != nil {
+		return entry{}, err
+	}
+	if idx != nil {
+		return entry{ref: ref, platformImage: idx}, nil
+	}
+	return entry{ref: ref, oci: img}, nil
 }
 
 type AddChartOpts struct {
@@ -110,7 +298,8 @@ func (o *AddChartOpts) AddFlags(cmd *cobra.Command) {
 
 	f.StringVar(&o.ChartOpts.RepoURL, "repo", "", "chart repository url where to locate the requested chart")
 	f.StringVar(&o.ChartOpts.Version, "version", "", "specify a version constraint for the chart version to use. This constraint can be a specific tag (e.g. 1.1.1) or it may reference a valid range (e.g. ^2.0.0). If this is not specified, the latest version is used")
-	f.BoolVar(&o.ChartOpts.Verify, "verify", false, "verify the package before using it")
+	f.BoolVar(&o.ChartOpts.Verify, "verify", false, "verify the package before using it, storing its provenance file with it")
+	f.StringVar(&o.ChartOpts.Keyring, "keyring", defaultKeyring(), "location of public keys used for verification")
 	f.StringVar(&o.ChartOpts.Username, "username", "", "chart repository username where to locate the requested chart")
 	f.StringVar(&o.ChartOpts.Password, "password", "", "chart repository password where to locate the requested chart")
 	f.StringVar(&o.ChartOpts.CertFile, "cert-file", "", "identify HTTPS client using this SSL certificate file")
@@ -125,37 +314,378 @@ func AddChartCmd(ctx context.Context, o *AddChartOpts, s *store.Layout, chartNam
 		Name:    chartName,
 		RepoURL: o.ChartOpts.RepoURL,
 		Version: o.ChartOpts.Version,
+		Verify:  o.ChartOpts.Verify,
+		Keyring: o.ChartOpts.Keyring,
+	}
+
+	if err := withCredentials(o.Keychain(), o.ChartOpts, cfg.RepoURL); err != nil {
+		return err
 	}
 
-	return storeChart(ctx, s, cfg, o.ChartOpts)
+	_, err := storeChart(ctx, s, cfg, o.ChartOpts, nil, remote.WithAuthFromKeychain(o.Keychain()))
+	return err
 }
 
-func storeChart(ctx context.Context, s *store.Layout, cfg v1alpha1.Chart, opts *action.ChartPathOptions) error {
-	l := log.FromContext(ctx)
+// withCredentials fills in a chart repository's credentials from the keychain, unless they were given explicitly
+func withCredentials(kc *auth.Keychain, opts *action.ChartPathOptions, repoURL string) error {
+	if repoURL == "" || opts.Username != "" || opts.Password != "" {
+		return nil
+	}
+
+	username, password, err := kc.Basic(repoURL)
+	if err != nil {
+		return err
+	}
+
+	opts.Username = username
+	opts.Password = password
+	return nil
+}
+
+// withVerification configures the verification of a chart as declared by its content
+func withVerification(opts *action.ChartPathOptions, cfg v1alpha1.Chart) {
+	opts.Verify = cfg.Verify
+	opts.Keyring = cfg.Keyring
+	if opts.Keyring == "" {
+		opts.Keyring = defaultKeyring()
+	}
+}
+
+// defaultKeyring returns gpg's public keyring, the same default as helm's
+func defaultKeyring() string {
+	if v, ok := os.LookupEnv("GNUPGHOME"); ok {
+		return filepath.Join(v, "pubring.gpg")
+	}
+	return filepath.Join(homedir.HomeDir(), ".gnupg", "pubring.gpg")
+}
+
+func storeChart(ctx context.Context, s *store.Layout, cfg v1alpha1.Chart, opts *action.ChartPathOptions, lck *lock.Lock, ropts ...remote.Option) (ocispec.Descriptor, error) {
+	it, err := chartEntry(cfg, opts, lck, ropts...)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return storeEntry(ctx, s, it, lck)
+}
+
+// chartEntry returns the entry of a chart, charts in OCI registries are pulled with the given remote options
+func chartEntry(cfg v1alpha1.Chart, opts *action.ChartPathOptions, lck *lock.Lock, ropts ...remote.Option) (entry, error) {
+	version, err := lck.ChartVersion(cfg)
+	if err != nil {
+		return entry{}, err
+	}
+
+	return chartVersionEntry(cfg, "", version, opts, lck, ropts...)
+}
+
+// chartVersionEntry returns the entry of a chart at version, which may still be a constraint.  Local charts and keyrings
+// are read relative to dir, while the chart is pinned as declared.
+func chartVersionEntry(cfg v1alpha1.Chart, dir string, version string, opts *action.ChartPathOptions, lck *lock.Lock, ropts ...remote.Option) (entry, error) {
+	local := localChart(dir, cfg)
 
 	// TODO: This shouldn't be necessary
 	opts.RepoURL = cfg.RepoURL
-	opts.Version = cfg.Version
+	opts.Version = version
+	withVerification(opts, local)
 
-	chrt, err := chart.NewChart(cfg.Name, opts)
+	chrt, err := chart.NewChart(local.Name, opts, ropts...)
 	if err != nil {
-		return err
+		return entry{}, err
 	}
 
 	c, err := chrt.Load()
 	if err != nil {
-		return err
+		return entry{}, err
+	}
+
+	if err := pinChart(lck, cfg, chrt); err != nil {
+		return entry{}, err
 	}
 
 	ref, err := reference.NewTagged(c.Name(), c.Metadata.Version)
 	if err != nil {
-		return err
+		return entry{}, err
 	}
-	desc, err := s.AddOCI(ctx, chrt, ref.Name())
+
+	return entry{ref: ref.Name(), oci: chrt}, nil
+}
+
+// entry is a single artifact to add to the store at ref
+type entry struct {
+	ref string
+	oci artifacts.OCI
+
+	// platformImage is set instead of oci for images restricted to a set of platforms
+	platformImage *content.PlatformImage
+
+	// verify is set for images whose signatures must be verified before they're stored
+	verify *v1alpha1.ImageVerification
+
+	// sha256 is set for files whose contents must match the checksum before they're stored
+	sha256 string
+
+	// pin is set for files read from somewhere other than the path they're declared at, which they're pinned to instead
+	pin string
+}
+
+// pinPath returns the path a file read from path is pinned to
+func (it entry) pinPath(path string) string {
+	if it.pin != "" {
+		return it.pin
+	}
+	return path
+}
+
+// finalizeEntry pins an entry's image to the lock and verifies its signatures, returning the entry followed by the
+// entry of its signatures when they were verified.  Images pinned elsewhere are pulled again with the given remote
+// options.
+func finalizeEntry(it entry, lck *lock.Lock, ropts ...remote.Option) ([]entry, error) {
+	if it.platformImage != nil {
+		if it.verify != nil {
+			return nil, fmt.Errorf("image %s: signatures can't be verified on an image restricted to platforms", it.platformImage.Name)
+		}
+
+		idx, err := pinPlatformImage(lck, it.platformImage, ropts...)
+		if err != nil {
+			return nil, err
+		}
+		it.platformImage = idx
+		return []entry{it}, nil
+	}
+
+	img, ok := it.oci.(*image.Image)
+	if !ok {
+		return []entry{it}, nil
+	}
+
+	pinned, err := pinImage(lck, img, ropts...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	it.oci = pinned
 
-	l.Infof("added 'chart' to store at [%s], with digest [%s]", ref.Name(), desc.Digest.String())
-	return nil
+	if it.verify == nil {
+		return []entry{it}, nil
+	}
+
+	r, err := name.ParseReference(pinned.Name)
+	if err != nil {
+		return nil, err
+	}
+	d, err := pinned.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	sigs, err := signature.Verify(r.Context(), d, *it.verify, ropts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return []entry{it, {ref: signature.Ref(r.Context(), d).Name(), oci: sigs}}, nil
+}
+
+// storeEntry pins an entry's files and adds it to the store, images are expected to be finalized already
+func storeEntry(ctx context.Context, s *store.Layout, it entry, lck *lock.Lock) (ocispec.Descriptor, error) {
+	l := log.FromContext(ctx)
+
+	// Artifacts computed into temporary files, like repositories, are cleaned up once stored
+	if c, ok := it.oci.(io.Closer); ok {
+		defer func() {
+			if err := c.Close(); err != nil {
+				l.Warnf("failed to clean up '%s': %v", it.ref, err)
+			}
+		}()
+	}
+
+	if it.platformImage != nil {
+		desc, err := addPlatformImage(ctx, s, it.platformImage, it.ref)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+
+		l.Infof("added '%s' to store at [%s], with digest [%s]", entryType(it), it.ref, desc.Digest.String())
+		return desc, nil
+	}
+
+	key, err := sourceKey(it, lck)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if desc, ok := storedSource(ctx, s, it.ref, key); ok {
+		if f, ok := it.oci.(*file.File); ok {
+			if err := lck.PinFile(it.pinPath(f.Path), fileDigest(it, lck)); err != nil {
+				return ocispec.Descriptor{}, err
+			}
+		}
+		l.Debugf("[%s] is unchanged, skipping", it.ref)
+		return desc, nil
+	}
+
+	switch f := it.oci.(type) {
+	case *file.File:
+		if it.sha256 != "" {
+			if err := verifyChecksum(f, it.sha256); err != nil {
+				return ocispec.Descriptor{}, err
+			}
+		}
+		d, err := pinFile(lck, it.pinPath(f.Path), f)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		if key == "" && d != "" {
+			key = it.pinPath(f.Path) + "@" + d
+		}
+	case *content.Directory:
+		if _, err := pinFile(lck, it.pinPath(f.Path), f); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+
+	desc, err := addOCI(ctx, s, it.oci, it.ref, key)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	l.Infof("added '%s' to store at [%s], with digest [%s]", entryType(it), it.ref, desc.Digest.String())
+	return desc, nil
+}
+
+// sourceAnnotation records the source key of an artifact on its descriptor in the store's index
+const sourceAnnotation = "content.hauler.cattle.io/source"
+
+// sourceKey returns a key identifying what an entry stores without fetching it: a file's path and the digest it's
+// known to have, declared by its checksum or pinned by the lock, or a repository's url and the commits of its refs.
+// Entries that can't be identified without fetching them have no key.
+func sourceKey(it entry, lck *lock.Lock) (string, error) {
+	switch o := it.oci.(type) {
+	case *file.File:
+		if d := fileDigest(it, lck); d != "" {
+			return it.pinPath(o.Path) + "@" + d, nil
+		}
+	case *content.Git:
+		return o.Key()
+	}
+	return "", nil
+}
+
+// fileDigest returns the digest a file entry is known to have without fetching it, if any
+func fileDigest(it entry, lck *lock.Lock) string {
+	if it.sha256 != "" {
+		return "sha256:" + it.sha256
+	}
+	if f, ok := it.oci.(*file.File); ok {
+		if d, err := lck.FileDigest(it.pinPath(f.Path)); err == nil {
+			return d
+		}
+	}
+	return ""
+}
+
+// storedSource returns the descriptor stored at ref when it was stored from the source identified by key
+func storedSource(ctx context.Context, s *store.Layout, ref string, key string) (ocispec.Descriptor, bool) {
+	if key == "" {
+		return ocispec.Descriptor{}, false
+	}
+
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	_, desc, err := s.Resolve(ctx, ref)
+	if err != nil || desc.Annotations[sourceAnnotation] != key {
+		return ocispec.Descriptor{}, false
+	}
+	return desc, true
+}
+
+// indexLock serializes updates to the store's index, which isn't safe for concurrent use
+var indexLock sync.Mutex
+
+// addOCI adds an artifact to the store, skipping it entirely when the store already holds the same manifest at ref.
+// The artifact's source key, if any, is recorded so it can be skipped without computing it the next time.
+func addOCI(ctx context.Context, s *store.Layout, oci artifacts.OCI, ref string, key string) (ocispec.Descriptor, error) {
+	l := log.FromContext(ctx)
+
+	m, err := oci.Manifest()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	mdata, err := json.Marshal(m)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	indexLock.Lock()
+	_, existing, err := s.Resolve(ctx, ref)
+	indexLock.Unlock()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if existing.Digest == digest.FromBytes(mdata) {
+		l.Debugf("[%s] is unchanged, skipping", ref)
+		indexLock.Lock()
+		defer indexLock.Unlock()
+		return withSource(s, existing, key)
+	}
+
+	// Fetch the layers concurrently ahead of adding the artifact, which then only writes the manifest and config
+	if err := layout.WriteLayers(s, oci); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	desc, err := s.AddOCI(ctx, oci, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return withSource(s, desc, key)
+}
+
+// withSource records a source key on a descriptor in the store's index, the index must be locked
+func withSource(s *store.Layout, desc ocispec.Descriptor, key string) (ocispec.Descriptor, error) {
+	if key == "" || desc.Annotations[sourceAnnotation] == key {
+		return desc, nil
+	}
+
+	annotations := make(map[string]string, len(desc.Annotations)+1)
+	for k, v := range desc.Annotations {
+		annotations[k] = v
+	}
+	annotations[sourceAnnotation] = key
+	desc.Annotations = annotations
+	return desc, s.OCI.AddIndex(desc)
+}
+
+// addPlatformImage adds a platform restricted image to the store as an image index, skipping it entirely when the store
+// already holds the same index at ref
+func addPlatformImage(ctx context.Context, s *store.Layout, idx *content.PlatformImage, ref string) (ocispec.Descriptor, error) {
+	l := log.FromContext(ctx)
+
+	d, err := idx.Index.Digest()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	indexLock.Lock()
+	_, existing, err := s.Resolve(ctx, ref)
+	indexLock.Unlock()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if existing.Digest.String() == d.String() {
+		l.Debugf("[%s] is unchanged, skipping", ref)
+		return existing, nil
+	}
+
+	desc, err := layout.WriteIndex(s, idx.Index)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc.Annotations = map[string]string{
+		ocispec.AnnotationRefName: ref,
+	}
+
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	return desc, s.OCI.AddIndex(desc)
 }
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancherfederal/ocil/pkg/artifacts/file"
+	"github.com/rancherfederal/ocil/pkg/store"
+
+	"github.com/rancherfederal/hauler/internal/layout"
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/content"
+	"github.com/rancherfederal/hauler/pkg/lock"
+	"github.com/rancherfederal/hauler/pkg/signature"
+)
+
+// newChangingServer serves original on the first download of a file, and tampered on every download after it, like a
+// mirror updated between fetches
+func newChangingServer(t *testing.T, original string, tampered string) string {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || atomic.AddInt32(&requests, 1) == 1 {
+			fmt.Fprint(w, original)
+			return
+		}
+		fmt.Fprint(w, tampered)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/install.sh"
+}
+
+func TestStoreEntryVerifiesWrittenChecksum(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := store.NewLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := "#!/bin/sh\necho original\n"
+	sum := sha256.Sum256([]byte(original))
+	u := newChangingServer(t, original, "#!/bin/sh\necho tampered\n")
+
+	it := entry{ref: "hauler/install.sh:latest", oci: file.NewFile(u), sha256: hex.EncodeToString(sum[:])}
+	if _, err := storeEntry(ctx, s, it, nil); !errors.Is(err, layout.ErrDigestMismatch) {
+		t.Fatalf("storeEntry() error = %v, want %v", err, layout.ErrDigestMismatch)
+	}
+
+	var refs []string
+	if err := s.Walk(func(reference string, _ ocispec.Descriptor) error {
+		refs = append(refs, reference)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 0 {
+		t.Errorf("store references = %v, want none", refs)
+	}
+}
+
+func TestStoreEntryVerifiesWrittenPin(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := store.NewLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := "#!/bin/sh\necho original\n"
+	sum := sha256.Sum256([]byte(original))
+	u := newChangingServer(t, original, "#!/bin/sh\necho tampered\n")
+
+	path := filepath.Join(t.TempDir(), lock.DefaultFilename)
+	l := lock.New()
+	if err := l.PinFile(u, "sha256:"+hex.EncodeToString(sum[:])); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := lock.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := entry{ref: "hauler/install.sh:latest", oci: file.NewFile(u)}
+	if _, err := storeEntry(ctx, s, it, locked); !errors.Is(err, layout.ErrDigestMismatch) {
+		t.Fatalf("storeEntry() error = %v, want %v", err, layout.ErrDigestMismatch)
+	}
+}
+
+// pushSigned pushes an image to ref along with a cosign signature of it, returning the path to the public key it was
+// signed with
+func pushSigned(t *testing.T, ref string) string {
+	t.Helper()
+
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(r, img); err != nil {
+		t.Fatal(err)
+	}
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"critical":{"identity":{"docker-reference":""},"image":{"docker-manifest-digest":"` + d.String() + `"},"type":"cosign container image signature"},"optional":null}`)
+	sum := sha256.Sum256(payload)
+	sig, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := static.NewLayer(payload, types.MediaType(signature.SimpleSigningMediaType))
+	sigs, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       l,
+		Annotations: map[string]string{signature.SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(signature.Ref(r.Context(), d), sigs); err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStoreImageVerifiesFromStore(t *testing.T) {
+	ctx := context.Background()
+
+	upstream := httptest.NewServer(registry.New())
+	defer upstream.Close()
+	mirror := httptest.NewServer(registry.New())
+	defer mirror.Close()
+	mirrorHost := strings.TrimPrefix(mirror.URL, "http://")
+
+	ref := strings.TrimPrefix(upstream.URL, "http://") + "/library/signed:v1"
+	cfg := v1alpha1.ImageVerification{Key: pushSigned(t, ref)}
+
+	s, err := store.NewLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storeImage(ctx, s, v1alpha1.Image{Name: ref, Verify: &cfg}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify what crossed the airgap, with upstream out of reach
+	upstream.Close()
+	if err := CopyCmd(ctx, &CopyOpts{RootOpts: &RootOpts{}, PlainHTTP: true}, s, "registry://"+mirrorHost); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := name.ParseReference(mirrorHost + "/library/signed:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signature.Verify(stored.Context(), desc.Digest, cfg); err != nil {
+		t.Errorf("Verify() of the stored image error = %v", err)
+	}
+}
+
+func TestImageEntryVerifiedPlatforms(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/library/signed:v1"
+	cfg := v1alpha1.ImageVerification{Key: pushSigned(t, ref)}
+
+	if _, err := imageEntry(v1alpha1.Image{Name: ref, Platforms: []string{"linux/arm64"}, Verify: &cfg}, nil); err == nil {
+		t.Errorf("imageEntry() with platforms and verify succeeded")
+	}
+
+	platforms, err := content.ParsePlatforms([]string{"linux/arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := imageEntry(v1alpha1.Image{Name: ref, Verify: &cfg}, platforms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.platformImage != nil {
+		t.Errorf("imageEntry() restricted a verified image to the default platforms")
+	}
+}
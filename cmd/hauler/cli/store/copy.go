@@ -52,7 +52,13 @@ func CopyCmd(ctx context.Context, o *CopyOpts, s *store.Layout, targetRef string
 
 	case "registry":
 		l.Debugf("identified registry target reference")
+		var configs []string
+		if f := o.dockerConfigFile(); f != "" {
+			configs = append(configs, f)
+		}
+
 		ropts := content.RegistryOptions{
+			Configs:   configs,
 			Username:  o.Username,
 			Password:  o.Password,
 			Insecure:  o.Insecure,
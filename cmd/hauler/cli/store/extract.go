@@ -4,13 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/downloader"
 
+	"github.com/rancherfederal/ocil/pkg/consts"
 	"github.com/rancherfederal/ocil/pkg/store"
 
 	"github.com/rancherfederal/hauler/internal/mapper"
+	"github.com/rancherfederal/hauler/pkg/content"
 	"github.com/rancherfederal/hauler/pkg/log"
 	"github.com/rancherfederal/hauler/pkg/reference"
 )
@@ -18,12 +25,16 @@ import (
 type ExtractOpts struct {
 	*RootOpts
 	DestinationDir string
+	Keyring        string
+	Bare           bool
 }
 
 func (o *ExtractOpts) AddArgs(cmd *cobra.Command) {
 	f := cmd.Flags()
 
 	f.StringVarP(&o.DestinationDir, "output", "o", "", "Directory to save contents to (defaults to current directory)")
+	f.StringVar(&o.Keyring, "keyring", "", "Verify extracted charts against the public keys in this keyring, using their provenance files")
+	f.BoolVar(&o.Bare, "bare", false, "Restore extracted git repositories as bare repositories instead of working clones")
 }
 
 func ExtractCmd(ctx context.Context, o *ExtractOpts, s *store.Layout, ref string) error {
@@ -64,6 +75,12 @@ func ExtractCmd(ctx context.Context, o *ExtractOpts, s *store.Layout, ref string
 
 		l.Infof("extracted [%s] from store with digest [%s]", pushedDesc.MediaType, pushedDesc.Digest.String())
 
+		switch {
+		case o.Keyring != "" && m.Config.MediaType == consts.ChartConfigMediaType:
+			return verifyChart(ctx, m, o.DestinationDir, o.Keyring)
+		case m.Config.MediaType == content.GitConfigMediaType:
+			return restoreGit(ctx, m, o.DestinationDir, o.Bare)
+		}
 		return nil
 	}); err != nil {
 		return err
@@ -75,3 +92,69 @@ func ExtractCmd(ctx context.Context, o *ExtractOpts, s *store.Layout, ref string
 
 	return nil
 }
+
+// verifyChart verifies an extracted chart against the keyring with its extracted provenance file
+func verifyChart(ctx context.Context, m ocispec.Manifest, dir string, keyring string) error {
+	l := log.FromContext(ctx)
+
+	var chartFile string
+	var hasProv bool
+	for _, desc := range m.Layers {
+		switch desc.MediaType {
+		case consts.ChartLayerMediaType:
+			chartFile = desc.Annotations[ocispec.AnnotationTitle]
+		case consts.ProvLayerMediaType:
+			hasProv = true
+		}
+	}
+	if chartFile == "" {
+		return fmt.Errorf("chart has no archive to verify")
+	}
+	if !hasProv {
+		return fmt.Errorf("chart %s has no provenance file to verify it with", chartFile)
+	}
+
+	ver, err := downloader.VerifyChart(filepath.Join(dir, chartFile), keyring)
+	if err != nil {
+		return fmt.Errorf("verify chart %s: %w", chartFile, err)
+	}
+
+	var signers []string
+	for id := range ver.SignedBy.Identities {
+		signers = append(signers, id)
+	}
+	sort.Strings(signers)
+	l.Infof("verified chart [%s] signed by [%s]", chartFile, strings.Join(signers, ", "))
+	return nil
+}
+
+// restoreGit restores an extracted repository from its bundle into a directory named after it, removing the bundle
+func restoreGit(ctx context.Context, m ocispec.Manifest, dir string, bare bool) error {
+	l := log.FromContext(ctx)
+
+	var bundle string
+	for _, desc := range m.Layers {
+		if desc.MediaType == content.GitBundleLayerMediaType {
+			bundle = desc.Annotations[ocispec.AnnotationTitle]
+		}
+	}
+	if bundle == "" {
+		return fmt.Errorf("repository has no bundle to restore")
+	}
+
+	path := filepath.Join(dir, bundle)
+	repo := strings.TrimSuffix(path, ".bundle")
+	if bare {
+		repo += ".git"
+	}
+
+	if err := content.RestoreGit(ctx, path, repo, m.Annotations[content.GitHeadAnnotation], bare); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	l.Infof("restored repository [%s]", repo)
+	return nil
+}
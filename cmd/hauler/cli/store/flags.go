@@ -10,6 +10,7 @@ import (
 	"github.com/rancherfederal/ocil/pkg/store"
 	"github.com/spf13/cobra"
 
+	"github.com/rancherfederal/hauler/pkg/auth"
 	"github.com/rancherfederal/hauler/pkg/log"
 )
 
@@ -19,14 +20,32 @@ const (
 )
 
 type RootOpts struct {
-	StoreDir string
-	CacheDir string
+	StoreDir     string
+	CacheDir     string
+	DockerConfig string
 }
 
 func (o *RootOpts) AddArgs(cmd *cobra.Command) {
 	pf := cmd.PersistentFlags()
 	pf.StringVar(&o.CacheDir, "cache", "", "Location of where to store cache data (defaults to $XDG_CACHE_DIR/hauler)")
 	pf.StringVarP(&o.StoreDir, "store", "s", DefaultStoreName, "Location to create store at")
+	pf.StringVar(&o.DockerConfig, "docker-config", "", "Location of the docker config (directory or config.json) and credential helpers used to authenticate to registries (defaults to $DOCKER_CONFIG or ~/.docker)")
+}
+
+// Keychain returns a keychain authenticating with the configured docker config
+func (o *RootOpts) Keychain() *auth.Keychain {
+	return auth.NewKeychain(o.DockerConfig)
+}
+
+// dockerConfigFile returns the path to the configured docker config.json, if any
+func (o *RootOpts) dockerConfigFile() string {
+	if o.DockerConfig == "" {
+		return ""
+	}
+	if fi, err := os.Stat(o.DockerConfig); err == nil && fi.IsDir() {
+		return filepath.Join(o.DockerConfig, "config.json")
+	}
+	return o.DockerConfig
 }
 
 func (o *RootOpts) Store(ctx context.Context) (*store.Layout, error) {
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	gtypes "github.com/google/go-containerregistry/pkg/v1/types"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
 
@@ -14,7 +16,9 @@ import (
 
 	"github.com/rancherfederal/ocil/pkg/store"
 
+	"github.com/rancherfederal/hauler/pkg/content"
 	"github.com/rancherfederal/hauler/pkg/reference"
+	"github.com/rancherfederal/hauler/pkg/signature"
 )
 
 type InfoOpts struct {
@@ -39,18 +43,21 @@ func InfoCmd(ctx context.Context, o *InfoOpts, s *store.Layout) error {
 			return nil
 		}
 
-		rc, err := s.Fetch(ctx, desc)
-		if err != nil {
-			return err
+		if isIndex(desc.MediaType) {
+			i, err := newIndexItem(ctx, s, desc)
+			if err != nil {
+				return err
+			}
+			items = append(items, i)
+			return nil
 		}
-		defer rc.Close()
 
 		var m ocispec.Manifest
-		if err := json.NewDecoder(rc).Decode(&m); err != nil {
+		if err := fetchJSON(ctx, s, desc, &m); err != nil {
 			return err
 		}
 
-		i := newItem(s, desc, m)
+		i := newItem(ctx, s, desc, m)
 		items = append(items, i)
 
 		return nil
@@ -74,12 +81,22 @@ func buildTable(items ...item) string {
 	b := strings.Builder{}
 	tw := tabwriter.NewWriter(&b, 1, 1, 3, ' ', 0)
 
-	fmt.Fprintf(tw, "Reference\tType\t# Layers\tSize\n")
-	fmt.Fprintf(tw, "---------\t----\t--------\t----\n")
+	fmt.Fprintf(tw, "Reference\tType\tPlatforms\t# Layers\tSize\tChecksum\n")
+	fmt.Fprintf(tw, "---------\t----\t---------\t--------\t----\t--------\n")
 
 	for _, i := range items {
-		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n",
-			i.Reference, i.Type, i.Layers, i.Size,
+		platforms := "-"
+		if len(i.Platforms) > 0 {
+			platforms = strings.Join(i.Platforms, ",")
+		}
+
+		checksum := "-"
+		if i.Checksum != "" {
+			checksum = "sha256:" + i.Checksum
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			i.Reference, i.Type, platforms, i.Layers, i.Size, checksum,
 		)
 	}
 	tw.Flush()
@@ -97,25 +114,45 @@ func buildJson(item ...item) string {
 type item struct {
 	Reference string
 	Type      string
+	Platforms []string
 	Layers    int
 	Size      string
+
+	// Checksum is the sha256 checksum a file was verified against when it was stored
+	Checksum string `json:",omitempty"`
 }
 
-func newItem(s *store.Layout, desc ocispec.Descriptor, m ocispec.Manifest) item {
+func newItem(ctx context.Context, s *store.Layout, desc ocispec.Descriptor, m ocispec.Manifest) item {
 	var size int64 = 0
 	for _, l := range m.Layers {
-		size = +l.Size
+		size += l.Size
 	}
 
 	// Generate a human-readable content type
 	var ctype string
+	var platforms []string
 	switch m.Config.MediaType {
-	case consts.DockerConfigJSON:
+	case consts.DockerConfigJSON, ocispec.MediaTypeImageConfig:
 		ctype = "image"
+
+		if len(m.Layers) > 0 && m.Layers[0].MediaType == signature.SimpleSigningMediaType {
+			ctype = "signature"
+			break
+		}
+
+		// An image's config declares its platform with the same fields as a platform
+		var p gv1.Platform
+		if err := fetchJSON(ctx, s, m.Config, &p); err == nil && p.OS != "" {
+			platforms = append(platforms, content.FormatPlatform(p))
+		}
 	case consts.ChartConfigMediaType:
 		ctype = "chart"
 	case consts.FileLocalConfigMediaType, consts.FileHttpConfigMediaType:
 		ctype = "file"
+	case consts.FileDirectoryConfigMediaType:
+		ctype = "directory"
+	case content.GitConfigMediaType:
+		ctype = "git"
 	default:
 		ctype = "unknown"
 	}
@@ -128,11 +165,86 @@ func newItem(s *store.Layout, desc ocispec.Descriptor, m ocispec.Manifest) item
 	return item{
 		Reference: ref.Name(),
 		Type:      ctype,
+		Platforms: platforms,
 		Layers:    len(m.Layers),
 		Size:      byteCountSI(size),
+		Checksum:  m.Annotations[content.ChecksumAnnotation],
 	}
 }
 
+// newIndexItem describes a multi-platform image by the platforms and combined layers of its manifests, including those
+// of any nested index
+func newIndexItem(ctx context.Context, s *store.Layout, desc ocispec.Descriptor) (item, error) {
+	platforms, layers, size, err := indexContents(ctx, s, desc)
+	if err != nil {
+		return item{}, err
+	}
+
+	ref, err := reference.Parse(desc.Annotations[ocispec.AnnotationRefName])
+	if err != nil {
+		return item{}, err
+	}
+
+	return item{
+		Reference: ref.Name(),
+		Type:      "image",
+		Platforms: platforms,
+		Layers:    layers,
+		Size:      byteCountSI(size),
+	}, nil
+}
+
+// indexContents returns the platforms, number of layers and combined size of the layers of an index's manifests,
+// descending into nested indexes
+func indexContents(ctx context.Context, s *store.Layout, desc ocispec.Descriptor) (platforms []string, layers int, size int64, err error) {
+	var idx ocispec.Index
+	if err := fetchJSON(ctx, s, desc, &idx); err != nil {
+		return nil, 0, 0, err
+	}
+
+	for _, child := range idx.Manifests {
+		if isIndex(child.MediaType) {
+			ps, l, sz, err := indexContents(ctx, s, child)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			platforms = append(platforms, ps...)
+			layers += l
+			size += sz
+			continue
+		}
+
+		if p := child.Platform; p != nil {
+			platforms = append(platforms, content.FormatPlatform(gv1.Platform{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant}))
+		}
+
+		var m ocispec.Manifest
+		if err := fetchJSON(ctx, s, child, &m); err != nil {
+			return nil, 0, 0, err
+		}
+		layers += len(m.Layers)
+		for _, l := range m.Layers {
+			size += l.Size
+		}
+	}
+	return platforms, layers, size, nil
+}
+
+// isIndex reports whether a media type is that of an image index or docker manifest list
+func isIndex(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex || mediaType == string(gtypes.DockerManifestList)
+}
+
+func fetchJSON(ctx context.Context, s *store.Layout, desc ocispec.Descriptor, v interface{}) error {
+	rc, err := s.Fetch(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return json.NewDecoder(rc).Decode(v)
+}
+
 func byteCountSI(b int64) string {
 	const unit = 1000
 	if b < unit {
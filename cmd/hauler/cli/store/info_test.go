@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancherfederal/ocil/pkg/store"
+
+	"github.com/rancherfederal/hauler/internal/layout"
+)
+
+func TestNewIndexItem(t *testing.T) {
+	s, err := store.NewLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	platformImage := func(p gv1.Platform) mutate.IndexAddendum {
+		img, err := random.Image(64, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mutate.IndexAddendum{Add: img, Descriptor: gv1.Descriptor{Platform: &p}}
+	}
+
+	// Nested indexes are described by the manifests they hold, rather than read as a manifest
+	nested := mutate.AppendManifests(empty.Index,
+		platformImage(gv1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}),
+		platformImage(gv1.Platform{OS: "linux", Architecture: "arm64"}),
+	)
+	idx := mutate.AppendManifests(empty.Index,
+		platformImage(gv1.Platform{OS: "linux", Architecture: "amd64"}),
+		mutate.IndexAddendum{Add: nested},
+	)
+
+	desc, err := layout.WriteIndex(s, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc.Annotations = map[string]string{ocispec.AnnotationRefName: "library/app:v1"}
+
+	got, err := newIndexItem(context.Background(), s, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Random layers are compressed, their size isn't known up front
+	want := item{
+		Reference: "library/app:v1",
+		Type:      "image",
+		Platforms: []string{"linux/amd64", "linux/arm/v7", "linux/arm64"},
+		Layers:    6,
+		Size:      got.Size,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newIndexItem() = %+v, want %+v", got, want)
+	}
+}
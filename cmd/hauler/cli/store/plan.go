@@ -0,0 +1,224 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/rancherfederal/ocil/pkg/artifacts/file"
+	"github.com/rancherfederal/ocil/pkg/artifacts/image"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+	"github.com/rancherfederal/hauler/pkg/content/chart"
+)
+
+// plan prints what a sync would add to the store, estimating the compressed size of each entry without fetching blobs
+func plan(o *SyncOpts, entries []entry) error {
+	planned := make([]plannedEntry, len(entries))
+	if err := parallel(o.Concurrency, len(entries), func(i int) error {
+		size, err := estimateSize(entries[i])
+		if err != nil {
+			return fmt.Errorf("estimate size of %s: %w", entries[i].ref, err)
+		}
+
+		planned[i] = plannedEntry{
+			Reference: entries[i].ref,
+			Type:      entryType(entries[i]),
+			Size:      size,
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	var msg string
+	switch o.OutputFormat {
+	case "json":
+		msg = buildPlanJson(planned...)
+
+	default:
+		msg = buildPlanTable(planned...)
+	}
+	fmt.Println(msg)
+	return nil
+}
+
+type plannedEntry struct {
+	Reference string `json:"reference"`
+	Type      string `json:"type"`
+
+	// Size is the estimated compressed size in bytes, or -1 when it can't be estimated
+	Size int64 `json:"size"`
+}
+
+func buildPlanTable(entries ...plannedEntry) string {
+	b := strings.Builder{}
+	tw := tabwriter.NewWriter(&b, 1, 1, 3, ' ', 0)
+
+	fmt.Fprintf(tw, "Reference\tType\tSize\n")
+	fmt.Fprintf(tw, "---------\t----\t----\n")
+
+	var total int64
+	for _, e := range entries {
+		size := "unknown"
+		if e.Size >= 0 {
+			size = byteCountSI(e.Size)
+			total += e.Size
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Reference, e.Type, size)
+	}
+
+	fmt.Fprintf(tw, "\t\t\n")
+	fmt.Fprintf(tw, "Total\t%d items\t%s\n", len(entries), byteCountSI(total))
+	tw.Flush()
+	return b.String()
+}
+
+func buildPlanJson(entries ...plannedEntry) string {
+	var total int64
+	for _, e := range entries {
+		if e.Size >= 0 {
+			total += e.Size
+		}
+	}
+
+	p := struct {
+		Items []plannedEntry `json:"items"`
+		Total int64          `json:"total"`
+	}{
+		Items: entries,
+		Total: total,
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// entryType returns a human-readable content type for an entry
+func entryType(it entry) string {
+	if it.platformImage != nil {
+		return "image"
+	}
+
+	switch it.oci.(type) {
+	case *image.Image:
+		return "image"
+	case *file.File:
+		return "file"
+	case *content.Generated:
+		return "file"
+	case *content.Directory:
+		return "directory"
+	case *content.Git:
+		return "git"
+	case *chart.Chart:
+		return "chart"
+	default:
+		return "unknown"
+	}
+}
+
+// estimateSize estimates an entry's compressed size from its manifests.  Files, directories and repositories are the
+// exception, computing their manifest requires reading their contents, so their size is estimated from their source
+// instead when possible.
+func estimateSize(it entry) (int64, error) {
+	if it.platformImage != nil {
+		return indexSize(it.platformImage.Index)
+	}
+
+	switch f := it.oci.(type) {
+	case *file.File:
+		return estimateFileSize(f.Path)
+	case *content.Generated:
+		return f.Size(), nil
+	case *content.Directory:
+		return f.Size()
+	case *content.Git:
+		// The size of a repository isn't known without cloning it
+		return -1, nil
+	}
+
+	m, err := it.oci.Manifest()
+	if err != nil {
+		return 0, err
+	}
+	return manifestSize(m), nil
+}
+
+func indexSize(idx gv1.ImageIndex) (int64, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return 0, err
+			}
+			s, err := indexSize(child)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+
+		case desc.MediaType.IsImage():
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return 0, err
+			}
+			m, err := img.Manifest()
+			if err != nil {
+				return 0, err
+			}
+			size += manifestSize(m)
+		}
+	}
+	return size, nil
+}
+
+func manifestSize(m *gv1.Manifest) int64 {
+	size := m.Config.Size
+	for _, l := range m.Layers {
+		size += l.Size
+	}
+	return size
+}
+
+func estimateFileSize(path string) (int64, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return 0, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		resp, err := http.Head(path)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return resp.ContentLength, nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rancherfederal/ocil/pkg/artifacts/file"
+	"github.com/rancherfederal/ocil/pkg/store"
+
+	"github.com/rancherfederal/hauler/pkg/lock"
+)
+
+func TestEstimateSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected %s request for %s, sizes are estimated without downloading", r.Method, r.URL.Path)
+		}
+
+		switch r.URL.Path {
+		case "/install.sh":
+			w.Header().Set("Content-Length", "2048")
+		case "/stream":
+			// Streamed responses don't know their length up front
+			w.Header().Set("Transfer-Encoding", "chunked")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	local := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(local, []byte("key: value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "should stat a local file",
+			path: local,
+			want: 11,
+		},
+		{
+			name: "should read the length of a remote file",
+			path: srv.URL + "/install.sh",
+			want: 2048,
+		},
+		{
+			name: "should report an unknown length as unknown",
+			path: srv.URL + "/stream",
+			want: -1,
+		},
+		{
+			name:    "should fail on a missing remote file",
+			path:    srv.URL + "/missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := estimateSize(entry{ref: "hauler/file:latest", oci: file.NewFile(tt.path)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("estimateSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("estimateSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	planned := []plannedEntry{
+		{Reference: "docker.io/library/busybox:1.35", Type: "image", Size: 2000000},
+		{Reference: "hauler/install.sh:latest", Type: "file", Size: -1},
+		{Reference: "hauler/podinfo:6.0.3", Type: "chart", Size: 500000},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		got := strings.Split(buildPlanTable(planned...), "\n")
+		want := []string{
+			"Reference                        Type      Size",
+			"---------                        ----      ----",
+			"docker.io/library/busybox:1.35   image     2.0 MB",
+			"hauler/install.sh:latest         file      unknown",
+			"hauler/podinfo:6.0.3             chart     500.0 kB",
+			"                                           ",
+			"Total                            3 items   2.5 MB",
+			"",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("buildPlanTable() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(buildPlanJson(planned...)), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"reference": "docker.io/library/busybox:1.35", "type": "image", "size": float64(2000000)},
+				map[string]interface{}{"reference": "hauler/install.sh:latest", "type": "file", "size": float64(-1)},
+				map[string]interface{}{"reference": "hauler/podinfo:6.0.3", "type": "chart", "size": float64(500000)},
+			},
+			"total": float64(2500000),
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("buildPlanJson() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && (strings.Contains(r.URL.Path, "/blobs/") || !strings.HasPrefix(r.URL.Path, "/v2/")) {
+			t.Errorf("unexpected download of %s, a dry run only reads manifests", r.URL.Path)
+		}
+		if strings.HasPrefix(r.URL.Path, "/v2/") {
+			reg.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", "2048")
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := name.ParseReference(host + "/library/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(r, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// The image isn't signed, verifying it would fail the sync
+	dir := t.TempDir()
+	contents := filepath.Join(dir, "contents.yaml")
+	doc := `apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Files
+spec:
+  files:
+  - path: ` + srv.URL + `/install.sh
+---
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Images
+spec:
+  images:
+  - name: ` + host + `/library/app:v1
+    verify:
+      key: cosign.pub
+`
+	if err := os.WriteFile(contents, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := store.NewLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &SyncOpts{RootOpts: &RootOpts{}, ContentFiles: []string{contents}, LockFile: filepath.Join(dir, lock.DefaultFilename), Concurrency: 1, DryRun: true}
+	if err := SyncCmd(context.Background(), o, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if refs := storeIndex(t, s); len(refs) != 0 {
+		t.Errorf("store references = %v, want none", refs)
+	}
+	if _, err := os.Stat(o.LockFile); !os.IsNotExist(err) {
+		t.Errorf("lock file written by a dry run: %v", err)
+	}
+}
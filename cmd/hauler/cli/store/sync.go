@@ -5,175 +5,1088 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/spf13/cobra"
 	"helm.sh/helm/v3/pkg/action"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/yaml"
 
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"github.com/rancherfederal/ocil/pkg/artifacts/file/getter"
+	"github.com/rancherfederal/ocil/pkg/artifacts/image"
 	"github.com/rancherfederal/ocil/pkg/store"
 
+	"github.com/rancherfederal/hauler/internal/layout"
+	"github.com/rancherfederal/hauler/internal/release"
 	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/auth"
 	tchart "github.com/rancherfederal/hauler/pkg/collection/chart"
 	"github.com/rancherfederal/hauler/pkg/collection/imagetxt"
 	"github.com/rancherfederal/hauler/pkg/collection/k3s"
+	"github.com/rancherfederal/hauler/pkg/collection/manifests"
+	"github.com/rancherfederal/hauler/pkg/collection/rancher"
+	"github.com/rancherfederal/hauler/pkg/collection/rke2"
 	"github.com/rancherfederal/hauler/pkg/content"
+	"github.com/rancherfederal/hauler/pkg/content/chart"
+	"github.com/rancherfederal/hauler/pkg/lock"
 	"github.com/rancherfederal/hauler/pkg/log"
 )
 
 type SyncOpts struct {
 	*RootOpts
 	ContentFiles []string
+	LockFile     string
+	Locked       bool
+	Prune        bool
+	Concurrency  int
+	DryRun       bool
+	OutputFormat string
+	Strict       bool
+	Set          []string
+	ValuesFiles  []string
+	Platforms    []string
+	KubeVersion  string
+	APIVersions  []string
 }
 
 func (o *SyncOpts) AddFlags(cmd *cobra.Command) {
 	f := cmd.Flags()
 
-	f.StringSliceVarP(&o.ContentFiles, "files", "f", []string{}, "Path to content files")
+	f.StringSliceVarP(&o.ContentFiles, "files", "f", []string{}, "Path to content files, can be a local path, an http(s) url, an oci:// reference, or - for stdin")
+	f.StringVar(&o.LockFile, "lockfile", lock.DefaultFilename, "Path to the lock file pinning every resolved item")
+	f.BoolVar(&o.Locked, "locked", false, "Only sync items pinned in the lock file, refusing anything that does not match it")
+	f.BoolVar(&o.Prune, "prune", false, "Remove content from the store that is no longer declared in the content files")
+	f.IntVar(&o.Concurrency, "concurrency", 1, "Number of items to fetch in parallel")
+	f.BoolVar(&o.DryRun, "dry-run", false, "Print the items that would be synced and their estimated sizes without fetching them")
+	f.StringVarP(&o.OutputFormat, "output", "o", "table", "Output format of --dry-run (table, json)")
+	f.StringArrayVar(&o.Set, "set", []string{}, "Set a variable referenced by the content files as ${key} (can specify multiple, key=value)")
+	f.StringSliceVar(&o.ValuesFiles, "values", []string{}, "Path to yaml files of variables referenced by the content files")
+	f.StringSliceVar(&o.Platforms, "platform", []string{}, "Only store the given platforms (os/arch[/variant]) of multi-platform images, unless an image declares its own or is verified")
+	f.StringVar(&o.KubeVersion, "kube-version", "", "Kubernetes version ThickCharts are rendered for, unless a chart declares its own")
+	f.StringSliceVar(&o.APIVersions, "api-versions", []string{}, "Kubernetes api versions available to ThickCharts in addition to those they declare (e.g. monitoring.coreos.com/v1)")
+	f.BoolVar(&o.Strict, "strict", false, "Fail on documents that are unrecognized or don't strictly match their content/collection type instead of skipping them")
 }
 
+// resolver resolves a single declared content or collection into the entries it adds to the store
+type resolver func() ([]entry, error)
+
 func SyncCmd(ctx context.Context, o *SyncOpts, s *store.Layout) error {
 	l := log.FromContext(ctx)
 
-	// Start from an empty store (contents are cached elsewhere)
-	l.Debugf("flushing content store")
-	if err := s.Flush(ctx); err != nil {
+	lck := lock.New()
+	if o.Locked {
+		l.Debugf("using lock file: '%s'", o.LockFile)
+		loaded, err := lock.Load(o.LockFile)
+		if err != nil {
+			return err
+		}
+		lck = loaded
+	}
+
+	vars, err := variables(o.Set, o.ValuesFiles)
+	if err != nil {
 		return err
 	}
 
+	platforms, err := content.ParsePlatforms(o.Platforms)
+	if err != nil {
+		return err
+	}
+
+	caps := v1alpha1.ChartCapabilities{
+		KubeVersion: o.KubeVersion,
+		APIVersions: o.APIVersions,
+	}
+	if _, err := tchart.NewCapabilities(caps.KubeVersion); err != nil {
+		return err
+	}
+
+	// Credentials declared by the content files are added to the keychain as they're read, ahead of resolving anything
+	kc := o.Keychain()
+	ropts := []remote.Option{remote.WithAuthFromKeychain(kc)}
+
+	var resolvers []resolver
 	for _, filename := range o.ContentFiles {
 		l.Debugf("processing content file: '%s'", filename)
-		fi, err := os.Open(filename)
+		docs, err := readDocs(ctx, filename, vars, ropts...)
 		if err != nil {
 			return err
 		}
 
-		reader := yaml.NewYAMLReader(bufio.NewReader(fi))
+		for i, doc := range docs {
+			if o.Strict {
+				if err := content.Validate(doc); err != nil {
+					return fmt.Errorf("%s: document %d: %w", filename, i+1, err)
+				}
+			}
 
-		var docs [][]byte
-		for {
-			raw, err := reader.Read()
-			if err == io.EOF {
-				break
+			obj, err := content.Load(doc)
+			if err != nil {
+				l.Debugf("skipping sync of unknown content")
+				continue
 			}
+
+			l.Infof("syncing [%s] to store", obj.GroupVersionKind().String())
+
+			rs, err := resolversFor(ctx, obj, doc, contentDir(filename), lck, kc, platforms, caps)
 			if err != nil {
 				return err
 			}
+			resolvers = append(resolvers, rs...)
+		}
+	}
+
+	// Resolve everything first, then fetch the resolved entries, both in parallel
+	resolved := make([][]entry, len(resolvers))
+	if err := parallel(o.Concurrency, len(resolvers), func(i int) error {
+		entries, err := resolvers[i]()
+		resolved[i] = entries
+		return err
+	}); err != nil {
+		return err
+	}
 
-			docs = append(docs, raw)
+	var unique []entry
+	seen := make(map[string]bool)
+	for _, r := range resolved {
+		for _, it := range r {
+			if seen[it.ref] {
+				continue
+			}
+			seen[it.ref] = true
+			unique = append(unique, it)
 		}
+	}
 
-		for _, doc := range docs {
-			obj, err := content.Load(doc)
-			if err != nil {
-				l.Debugf("skipping sync of unknown content")
+	// A dry run only plans what the resolved entries would add, without pinning, verifying or storing any of them
+	if o.DryRun {
+		return plan(o, unique)
+	}
+
+	// Pin and verify every image before anything is stored, so a single unverified image fails the sync up front
+	finalized := make([][]entry, len(unique))
+	if err := parallel(o.Concurrency, len(unique), func(i int) error {
+		entries, err := finalizeEntry(unique[i], lck, ropts...)
+		finalized[i] = entries
+		return err
+	}); err != nil {
+		return err
+	}
+
+	// Track every reference declared by the content files, anything else in the store is eligible for pruning
+	declared := make(map[string]bool)
+	var entries []entry
+	for _, f := range finalized {
+		for _, it := range f {
+			if declared[it.ref] {
 				continue
 			}
+			declared[it.ref] = true
+			entries = append(entries, it)
+		}
+	}
 
-			l.Infof("syncing [%s] to store", obj.GroupVersionKind().String())
+	if err := parallel(o.Concurrency, len(entries), func(i int) error {
+		_, err := storeEntry(ctx, s, entries[i], lck)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err := layout.SortIndex(s); err != nil {
+		return err
+	}
+
+	if !lck.Locked() {
+		l.Infof("writing lock file: '%s'", o.LockFile)
+		if err := lck.Save(o.LockFile); err != nil {
+			return err
+		}
+	}
+
+	if o.Prune {
+		removed, err := layout.Prune(ctx, s, declared)
+		if err != nil {
+			return err
+		}
+		for _, ref := range removed {
+			l.Infof("pruned [%s] from store", ref)
+		}
+	}
+
+	return nil
+}
+
+// variables combines variables from values files and key=value pairs, with pairs taking precedence
+func variables(set []string, valuesFiles []string) (content.Variables, error) {
+	var sets []content.Variables
+	for _, path := range valuesFiles {
+		vars, err := content.LoadVariables(path)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, vars)
+	}
 
-			// TODO: Should type switch instead...
-			switch obj.GroupVersionKind().Kind {
-			case v1alpha1.FilesContentKind:
-				var cfg v1alpha1.Files
-				if err := yaml.Unmarshal(doc, &cfg); err != nil {
-					return err
+	vars, err := content.ParseVariables(set)
+	if err != nil {
+		return nil, err
+	}
+
+	return content.Merge(append(sets, vars)...), nil
+}
+
+// readDocs reads every yaml document in a content file, rendering the variables each references
+func readDocs(ctx context.Context, filename string, vars content.Variables, ropts ...remote.Option) ([][]byte, error) {
+	fi, err := openContentFile(ctx, filename, ropts...)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	reader := yaml.NewYAMLReader(bufio.NewReader(fi))
+
+	var docs [][]byte
+	for {
+		raw, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := content.Render(raw, vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", filename, len(docs)+1, err)
+		}
+
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// contentDir returns the directory of a local content file, or an empty string for content files that aren't local
+func contentDir(source string) string {
+	if source == "-" {
+		return ""
+	}
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" {
+		return ""
+	}
+	return filepath.Dir(source)
+}
+
+// localPath returns a path declared by a content file, relative paths are relative to dir, the directory of the
+// content file.  Absolute paths and urls are returned unchanged, as is every path of a content file that isn't local.
+func localPath(dir string, path string) string {
+	if dir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	if u, err := url.Parse(path); err != nil || u.Scheme != "" {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// localVerification returns an image verification with its keys and certificates relative to dir
+func localVerification(dir string, v *v1alpha1.ImageVerification) *v1alpha1.ImageVerification {
+	if v == nil {
+		return nil
+	}
+
+	lv := *v
+	lv.Key = localPath(dir, v.Key)
+	if v.Keyless != nil {
+		keyless := *v.Keyless
+		keyless.Roots = localPath(dir, keyless.Roots)
+		keyless.RekorKey = localPath(dir, keyless.RekorKey)
+		lv.Keyless = &keyless
+	}
+	return &lv
+}
+
+// localChart returns a chart with its keyring, and the chart itself when it's a local chart, relative to dir.  Charts
+// from repositories, registries or helm's configured repositories (repo/name) are left as they are.
+func localChart(dir string, ch v1alpha1.Chart) v1alpha1.Chart {
+	ch.Keyring = localPath(dir, ch.Keyring)
+	if ch.RepoURL == "" && !chart.IsOCI(ch.Name) {
+		if p := localPath(dir, ch.Name); p != ch.Name {
+			if _, err := os.Stat(p); err == nil {
+				ch.Name = p
+			}
+		}
+	}
+	return ch
+}
+
+// localValues returns chart values with their values files relative to dir
+func localValues(dir string, v v1alpha1.ChartValues) v1alpha1.ChartValues {
+	files := make([]string, 0, len(v.ValuesFiles))
+	for _, f := range v.ValuesFiles {
+		files = append(files, localPath(dir, f))
+	}
+	v.ValuesFiles = files
+	return v
+}
+
+// openContentFile opens a content file from stdin ("-"), a local path, an http(s) url, or an oci:// reference to an
+// artifact whose layers are each content files, pulled with the given remote options.  Anything but a successful
+// response to an http(s) url is an error, rather than a document to parse.
+func openContentFile(ctx context.Context, source string, ropts ...remote.Option) (io.ReadCloser, error) {
+	if source == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	if strings.HasPrefix(source, "oci://") {
+		img, err := content.NewImage(strings.TrimPrefix(source, "oci://"), ropts...)
+		if err != nil {
+			return nil, fmt.Errorf("fetch content file %s: %w", source, err)
+		}
+
+		layers, err := img.Layers()
+		if err != nil {
+			return nil, fmt.Errorf("fetch content file %s: %w", source, err)
+		}
+
+		var readers []io.Reader
+		var closers []io.Closer
+		for i, l := range layers {
+			rc, err := l.Compressed()
+			if err != nil {
+				for _, c := range closers {
+					c.Close()
 				}
+				return nil, fmt.Errorf("fetch content file %s: %w", source, err)
+			}
 
-				for _, f := range cfg.Spec.Files {
-					err := storeFile(ctx, s, f)
-					if err != nil {
-						return err
-					}
+			if i > 0 {
+				readers = append(readers, strings.NewReader("\n---\n"))
+			}
+			readers = append(readers, rc)
+			closers = append(closers, rc)
+		}
+
+		return multiReadCloser{Reader: io.MultiReader(readers...), closers: closers}, nil
+	}
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetch content file %s: %w", source, err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetch content file %s: %s", source, resp.Status)
+		}
+		return resp.Body, nil
+	}
+
+	return getter.NewClient(getter.ClientOptions{}).ContentFrom(ctx, source)
+}
+
+type multiReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m multiReadCloser) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// resolversFor returns a resolver for every content or collection declared by a document, images are restricted to
+// platforms when any are given and charts are rendered against caps unless they declare their own.  Every relative
+// local path the document declares is relative to dir, the directory of the document's content file.
+func resolversFor(ctx context.Context, obj schema.ObjectKind, doc []byte, dir string, lck *lock.Lock, kc *auth.Keychain, platforms []gv1.Platform, caps v1alpha1.ChartCapabilities) ([]resolver, error) {
+	l := log.FromContext(ctx)
+
+	var resolvers []resolver
+	ropts := []remote.Option{remote.WithAuthFromKeychain(kc)}
+
+	// TODO: Should type switch instead...
+	switch obj.GroupVersionKind().Kind {
+	case v1alpha1.FilesContentKind:
+		var cfg v1alpha1.Files
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		for _, f := range cfg.Spec.Files {
+			f := f
+			resolvers = append(resolvers, func() ([]entry, error) {
+				lf := f
+				lf.Path = localPath(dir, f.Path)
+				lf.ChecksumURL = localPath(dir, f.ChecksumURL)
+
+				it, err := fileEntry(ctx, lf)
+				if lf.Path != f.Path {
+					it.pin = f.Path
 				}
+				return []entry{it}, err
+			})
+		}
+
+	case v1alpha1.GitsContentKind:
+		var cfg v1alpha1.Gits
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		for _, g := range cfg.Spec.Repositories {
+			g := g
+			g.URL = localPath(dir, g.URL)
+			resolvers = append(resolvers, func() ([]entry, error) {
+				it, err := gitEntry(g)
+				return []entry{it}, err
+			})
+		}
+
+	case v1alpha1.ImagesContentKind:
+		var cfg v1alpha1.Images
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		for _, i := range cfg.Spec.Images {
+			i := i
+			i.Verify = localVerification(dir, i.Verify)
+			resolvers = append(resolvers, func() ([]entry, error) {
+				it, err := imageEntry(i, platforms, ropts...)
+				return []entry{it}, err
+			})
+		}
+
+	case v1alpha1.ImageRepositoriesContentKind:
+		var cfg v1alpha1.ImageRepositories
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
 
-			case v1alpha1.ImagesContentKind:
-				var cfg v1alpha1.Images
-				if err := yaml.Unmarshal(doc, &cfg); err != nil {
-					return err
+		for _, r := range cfg.Spec.Repositories {
+			r := r
+			r.Verify = localVerification(dir, r.Verify)
+			resolvers = append(resolvers, func() ([]entry, error) {
+				tags, err := imageTags(r, lck, ropts...)
+				if err != nil {
+					return nil, err
 				}
+				l.Infof("selected tags [%s] of image repository [%s]", strings.Join(tags, ", "), r.Name)
 
-				for _, i := range cfg.Spec.Images {
-					err := storeImage(ctx, s, i)
+				var entries []entry
+				for _, tag := range tags {
+					i := v1alpha1.Image{Name: r.Name + ":" + tag, Platforms: r.Platforms, Verify: r.Verify}
+					it, err := imageEntry(i, platforms, ropts...)
 					if err != nil {
-						return err
+						return nil, err
 					}
+					entries = append(entries, it)
 				}
+				return entries, nil
+			})
+		}
+
+	case v1alpha1.ChartsContentKind:
+		var cfg v1alpha1.Charts
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
 
-			case v1alpha1.ChartsContentKind:
-				var cfg v1alpha1.Charts
-				if err := yaml.Unmarshal(doc, &cfg); err != nil {
-					return err
+		for _, ch := range cfg.Spec.Charts {
+			ch := ch
+			resolvers = append(resolvers, func() ([]entry, error) {
+				// TODO: Provide a way to configure syncs
+				opts := &action.ChartPathOptions{RepoURL: ch.RepoURL}
+				if err := withCredentials(kc, opts, ch.RepoURL); err != nil {
+					return nil, err
 				}
 
-				for _, ch := range cfg.Spec.Charts {
-					// TODO: Provide a way to configure syncs
-					err := storeChart(ctx, s, ch, &action.ChartPathOptions{})
+				versions, err := chartVersions(ctx, ch, opts, lck, ropts...)
+				if err != nil {
+					return nil, err
+				}
+
+				var entries []entry
+				for _, version := range versions {
+					vopts := *opts
+					it, err := chartVersionEntry(ch, dir, version, &vopts, lck, ropts...)
 					if err != nil {
-						return err
+						return nil, err
 					}
+					entries = append(entries, it)
 				}
+				return entries, nil
+			})
+		}
+
+	case v1alpha1.K3sCollectionKind:
+		var cfg v1alpha1.K3s
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		endpoints := release.Endpoints{
+			Release:       cfg.Spec.ReleaseURL,
+			Channel:       cfg.Spec.ChannelURL,
+			InstallScript: cfg.Spec.InstallScriptURL,
+		}.WithDefaults(k3s.DefaultEndpoints)
 
-			case v1alpha1.K3sCollectionKind:
-				var cfg v1alpha1.K3s
-				if err := yaml.Unmarshal(doc, &cfg); err != nil {
-					return err
+		arches := k3sArches(cfg.Spec)
+		kplatforms, err := archPlatforms(arches, platforms)
+		if err != nil {
+			return nil, err
+		}
+
+		resolvers = append(resolvers, func() ([]entry, error) {
+			version, err := lck.K3sVersion(cfg.Spec.Version)
+			if err != nil {
+				return nil, err
+			}
+			if !lck.Locked() {
+				if version, err = release.ResolveVersion(version, endpoints); err != nil {
+					return nil, err
 				}
+			}
+			if err := lck.PinK3s(cfg.Spec.Version, version); err != nil {
+				return nil, err
+			}
 
-				k, err := k3s.NewK3s(cfg.Spec.Version)
-				if err != nil {
-					return err
+			k, err := k3s.NewK3s(version, arches, endpoints, ropts...)
+			if err != nil {
+				return nil, err
+			}
+
+			return collectionEntries(k, kplatforms, ropts...)
+		})
+
+	case v1alpha1.RKE2CollectionKind:
+		var cfg v1alpha1.RKE2
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		endpoints := release.Endpoints{
+			Release:       cfg.Spec.ReleaseURL,
+			Channel:       cfg.Spec.ChannelURL,
+			InstallScript: cfg.Spec.InstallScriptURL,
+		}.WithDefaults(rke2.DefaultEndpoints)
+
+		var arches []string
+		if cfg.Spec.Arch != "" {
+			arches = append(arches, cfg.Spec.Arch)
+		}
+		rplatforms, err := archPlatforms(arches, platforms)
+		if err != nil {
+			return nil, err
+		}
+
+		resolvers = append(resolvers, func() ([]entry, error) {
+			version, err := lck.RKE2Version(cfg.Spec.Version)
+			if err != nil {
+				return nil, err
+			}
+			if !lck.Locked() {
+				if version, err = release.ResolveVersion(version, endpoints); err != nil {
+					return nil, err
 				}
+			}
+			if err := lck.PinRKE2(cfg.Spec.Version, version); err != nil {
+				return nil, err
+			}
+
+			r, err := rke2.NewRKE2(version, cfg.Spec.Arch, endpoints, ropts...)
+			if err != nil {
+				return nil, err
+			}
+
+			return collectionEntries(r, rplatforms, ropts...)
+		})
+
+	case v1alpha1.RancherCollectionKind:
+		var cfg v1alpha1.Rancher
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		endpoints := rancher.Endpoints{
+			Endpoints: release.Endpoints{Release: cfg.Spec.ReleaseURL},
+			ChartRepo: cfg.Spec.ChartRepoURL,
+		}
+		if endpoints.ChartRepo == "" {
+			endpoints.ChartRepo = rancher.DefaultChartRepoURL
+		}
+		chrt := v1alpha1.Chart{Name: rancher.ChartName, RepoURL: endpoints.ChartRepo, Version: rancher.ChartVersion(cfg.Spec.Version)}
+
+		resolvers = append(resolvers, func() ([]entry, error) {
+			opts := &action.ChartPathOptions{}
+			if err := withCredentials(kc, opts, endpoints.ChartRepo); err != nil {
+				return nil, err
+			}
 
-				if _, err := s.AddOCICollection(ctx, k); err != nil {
-					return err
+			r, err := rancher.NewRancher(cfg.Spec.Version, cfg.Spec.ImageLists, cfg.Spec.Sources, endpoints, opts, ropts...)
+			if err != nil {
+				return nil, err
+			}
+
+			entries, err := collectionEntries(r, platforms, ropts...)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, it := range entries {
+				if ch, ok := it.oci.(*chart.Chart); ok {
+					if err := pinChart(lck, chrt, ch); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return entries, nil
+		})
+
+	case v1alpha1.ChartsCollectionKind:
+		var cfg v1alpha1.ThickCharts
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		for _, cfg := range cfg.Spec.Charts {
+			cfg := cfg
+			if cfg.KubeVersion == "" {
+				cfg.KubeVersion = caps.KubeVersion
+			}
+			cfg.APIVersions = append(append([]string{}, caps.APIVersions...), cfg.APIVersions...)
+
+			resolvers = append(resolvers, func() ([]entry, error) {
+				local := cfg
+				local.Chart = localChart(dir, cfg.Chart)
+				local.ChartValues = localValues(dir, cfg.ChartValues)
+				local.ValueSets = nil
+				for _, vs := range cfg.ValueSets {
+					local.ValueSets = append(local.ValueSets, localValues(dir, vs))
 				}
 
-			case v1alpha1.ChartsCollectionKind:
-				var cfg v1alpha1.ThickCharts
-				if err := yaml.Unmarshal(doc, &cfg); err != nil {
-					return err
+				opts := &action.ChartPathOptions{RepoURL: cfg.RepoURL}
+				withVerification(opts, local.Chart)
+				if err := withCredentials(kc, opts, cfg.RepoURL); err != nil {
+					return nil, err
+				}
+
+				versions, err := chartVersions(ctx, cfg.Chart, opts, lck, ropts...)
+				if err != nil {
+					return nil, err
 				}
 
-				for _, cfg := range cfg.Spec.Charts {
-					tc, err := tchart.NewThickChart(cfg, &action.ChartPathOptions{
-						RepoURL: cfg.RepoURL,
-						Version: cfg.Version,
-					})
+				var entries []entry
+				for _, version := range versions {
+					vopts := *opts
+					vopts.Version = version
+
+					tc, err := tchart.NewThickChart(local, &vopts, ropts...)
 					if err != nil {
-						return err
+						return nil, err
 					}
 
-					if _, err := s.AddOCICollection(ctx, tc); err != nil {
-						return err
+					ves, err := collectionEntries(tc, platforms, ropts...)
+					if err != nil {
+						return nil, err
 					}
+
+					if d, ok := tc.(tchart.Detector); ok {
+						detections, err := d.Detections()
+						if err != nil {
+							return nil, err
+						}
+						for _, det := range detections {
+							l.Debugf("detected image [%s] in [%s] of chart [%s] at [%s]", det.Image, det.Resource, cfg.Chart.Name, det.Path)
+						}
+					}
+
+					if sk, ok := tc.(tchart.Skipper); ok {
+						skipped, err := sk.Skipped()
+						if err != nil {
+							return nil, err
+						}
+						for _, img := range skipped {
+							l.Warnf("skipping image [%s] discovered in chart [%s]: %v", img.Image, cfg.Chart.Name, img.Err)
+						}
+					}
+
+					for _, it := range ves {
+						if ch, ok := it.oci.(*chart.Chart); ok {
+							if err := pinChart(lck, cfg.Chart, ch); err != nil {
+								return nil, err
+							}
+						}
+					}
+					entries = append(entries, ves...)
 				}
+				return entries, nil
+			})
+		}
+
+	case v1alpha1.ManifestsCollectionKind:
+		var cfg v1alpha1.Manifests
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
 
-			case v1alpha1.ImageTxtsContentKind:
-				var cfg v1alpha1.ImageTxts
-				if err := yaml.Unmarshal(doc, &cfg); err != nil {
-					return err
+		for _, cfg := range cfg.Spec.Manifests {
+			cfg := cfg
+			cfg.Path = localPath(dir, cfg.Path)
+			resolvers = append(resolvers, func() ([]entry, error) {
+				m, err := manifests.NewManifests(cfg, ropts...)
+				if err != nil {
+					return nil, err
 				}
 
-				for _, cfgIt := range cfg.Spec.ImageTxts {
-					it, err := imagetxt.New(cfgIt.Ref,
-						imagetxt.WithIncludeSources(cfgIt.Sources.Include...),
-						imagetxt.WithExcludeSources(cfgIt.Sources.Exclude...),
-					)
+				entries, err := collectionEntries(m, platforms, ropts...)
+				if err != nil {
+					return nil, err
+				}
+
+				if d, ok := m.(tchart.Detector); ok {
+					detections, err := d.Detections()
 					if err != nil {
-						return fmt.Errorf("convert ImageTxt %s: %v", cfg.Name, err)
+						return nil, err
 					}
-
-					if _, err := s.AddOCICollection(ctx, it); err != nil {
-						return fmt.Errorf("add ImageTxt %s to store: %v", cfg.Name, err)
+					for _, det := range detections {
+						l.Debugf("detected image [%s] in [%s] of manifests [%s] at [%s]", det.Image, det.Resource, cfg.Path, det.Path)
 					}
 				}
+				return entries, nil
+			})
+		}
+
+	case v1alpha1.ImageTxtsContentKind:
+		var cfg v1alpha1.ImageTxts
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		for _, cfgIt := range cfg.Spec.ImageTxts {
+			cfgIt := cfgIt
+			cfgIt.Ref = localPath(dir, cfgIt.Ref)
+			resolvers = append(resolvers, func() ([]entry, error) {
+				it, err := imagetxt.New(cfgIt.Ref,
+					imagetxt.WithIncludeSources(cfgIt.Sources.Include...),
+					imagetxt.WithExcludeSources(cfgIt.Sources.Exclude...),
+					imagetxt.WithRemoteOptions(ropts...),
+				)
+				if err != nil {
+					return nil, fmt.Errorf("convert ImageTxt %s: %v", cfg.Name, err)
+				}
+
+				entries, err := collectionEntries(it, platforms, ropts...)
+				if err != nil {
+					return nil, fmt.Errorf("add ImageTxt %s to store: %v", cfg.Name, err)
+				}
+				return entries, nil
+			})
+		}
+
+	case v1alpha1.CredentialsContentKind:
+		var cfg v1alpha1.Credentials
+		if err := yaml.Unmarshal(doc, &cfg); err != nil {
+			return nil, err
+		}
+
+		for i, c := range cfg.Spec.Registries {
+			cfg.Spec.Registries[i].Password.File = localPath(dir, c.Password.File)
+		}
+		if err := kc.Add(cfg.Spec.Registries...); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unrecognized content/collection type: %s", obj.GroupVersionKind().String())
+	}
+
+	return resolvers, nil
+}
+
+// chartVersions returns the versions of a chart to sync: the single version (or constraint) to resolve, or every
+// version selected by a range of versions.  When locked, the pinned versions are returned instead.
+func chartVersions(ctx context.Context, cfg v1alpha1.Chart, opts *action.ChartPathOptions, lck *lock.Lock, ropts ...remote.Option) ([]string, error) {
+	l := log.FromContext(ctx)
+
+	switch cfg.Versions {
+	case "":
+		version, err := lck.ChartVersion(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []string{version}, nil
+
+	case v1alpha1.ChartVersionsRange:
+		versions, err := lck.ChartVersions(cfg)
+		if err != nil {
+			return nil, err
+		}
 
-			default:
-				return fmt.Errorf("unrecognized content/collection type: %s", obj.GroupVersionKind().String())
+		if versions == nil {
+			vopts := *opts
+			vopts.Version = cfg.Version
+			if versions, err = chart.Versions(cfg.Name, &vopts, cfg.MaxVersions, ropts...); err != nil {
+				return nil, err
 			}
 		}
+
+		l.Infof("selected versions [%s] of chart [%s] matching [%s]", strings.Join(versions, ", "), cfg.Name, cfg.Version)
+		return versions, nil
 	}
 
-	return nil
+	return nil, fmt.Errorf("chart %s: unknown versions %q, must be empty or %q", cfg.Name, cfg.Versions, v1alpha1.ChartVersionsRange)
+}
+
+// imageTags returns the tags of an image repository selected by its filter.  When locked, only the pinned tags are
+// selected instead of listing the repository.
+func imageTags(r v1alpha1.ImageRepository, lck *lock.Lock, ropts ...remote.Option) ([]string, error) {
+	pinned, err := lck.ImageTags(r.Name)
+	if err != nil {
+		return nil, err
+	}
+	if pinned == nil {
+		tags, err := content.Tags(r.Name, r.Tags, ropts...)
+		if err != nil {
+			return nil, err
+		}
+		return tags, lck.PinImageTags(r.Name, tags)
+	}
+
+	tags, err := content.FilterTags(pinned, r.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("image repository %s: %w", r.Name, err)
+	}
+	return tags, nil
+}
+
+// collectionEntries returns a collection's contents as entries ordered by reference, its images are restricted to
+// platforms when any are given
+func collectionEntries(c artifacts.OCICollection, platforms []gv1.Platform, ropts ...remote.Option) ([]entry, error) {
+	contents, err := c.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]string, 0, len(contents))
+	for ref := range contents {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	entries := make([]entry, 0, len(refs))
+	for _, ref := range refs {
+		if img, ok := contents[ref].(*image.Image); ok && len(platforms) > 0 {
+			it, err := newImageEntry(ref, img.Name, platforms, ropts...)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, it)
+			continue
+		}
+
+		entries = append(entries, entry{ref: ref, oci: contents[ref]})
+	}
+	return entries, nil
+}
+
+// k3sArches returns every architecture a k3s collection declares, its arch first, without duplicates
+func k3sArches(spec v1alpha1.K3sSpec) []string {
+	var arches []string
+	seen := make(map[string]bool)
+	for _, arch := range append([]string{spec.Arch}, spec.Arches...) {
+		if arch == "" || seen[arch] {
+			continue
+		}
+		seen[arch] = true
+		arches = append(arches, arch)
+	}
+	return arches
+}
+
+// archPlatforms restricts the images of a collection to the linux platforms of its declared architectures, unless
+// platforms are given or none are declared
+func archPlatforms(arches []string, platforms []gv1.Platform) ([]gv1.Platform, error) {
+	if len(platforms) > 0 || len(arches) == 0 {
+		return platforms, nil
+	}
+
+	var ss []string
+	for _, arch := range arches {
+		if arch == "armhf" {
+			arch = "arm"
+		}
+		ss = append(ss, "linux/"+arch)
+	}
+	return content.ParsePlatforms(ss)
+}
+
+// parallel calls fn for every index below n, running at most limit calls at once.  Every call is made regardless of
+// failures, and any errors are reported in index order.
+func parallel(limit int, n int, fn func(i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	errs := make([]error, n)
+	sem := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	}
+
+	msgs := make([]string, len(failed))
+	for i, err := range failed {
+		msgs[i] = err.Error()
+	}
+	return fmt.Errorf("%d items failed: %s", len(failed), strings.Join(msgs, "; "))
+}
+
+// pinImage pins an image to the digest it resolved to, when locked an image that resolved elsewhere is pulled again by
+// its pinned digest
+func pinImage(lck *lock.Lock, img *image.Image, ropts ...remote.Option) (*image.Image, error) {
+	d, err := img.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	pinned, err := lck.ImageDigest(img.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if pinned != "" && pinned != d.String() {
+		r, err := name.ParseReference(img.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		pimg, err := content.NewImage(r.Context().Digest(pinned).String(), ropts...)
+		if err != nil {
+			return nil, err
+		}
+		pimg.Name = img.Name
+
+		img = pimg
+		if d, err = img.Digest(); err != nil {
+			return nil, err
+		}
+	}
+
+	return img, lck.PinImage(img.Name, d.String())
+}
+
+// pinPlatformImage pins a platform restricted image to the digest of the complete index it was selected from, when
+// locked an index that resolved elsewhere is pulled again by its pinned digest and restricted to the same platforms
+func pinPlatformImage(lck *lock.Lock, idx *content.PlatformImage, ropts ...remote.Option) (*content.PlatformImage, error) {
+	pinned, err := lck.ImageDigest(idx.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if pinned != "" && pinned != idx.Source.String() {
+		r, err := name.ParseReference(idx.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		_, pidx, err := content.NewPlatformImage(r.Context().Digest(pinned).String(), idx.Platforms, ropts...)
+		if err != nil {
+			return nil, err
+		}
+		if pidx == nil {
+			return nil, fmt.Errorf("image %s is pinned to %s, which is not a multi-platform image", idx.Name, pinned)
+		}
+		pidx.Name = idx.Name
+
+		idx = pidx
+	}
+
+	return idx, lck.PinImage(idx.Name, idx.Source.String())
+}
+
+// pinFile pins a file, or a directory, declared at path to the digest of its contents and returns the digest.  Files are
+// fetched again to be stored, and what's stored is verified against the same digest as it's written.
+func pinFile(lck *lock.Lock, path string, f artifacts.OCI) (string, error) {
+	if lck == nil {
+		return "", nil
+	}
+
+	layers, err := f.Layers()
+	if err != nil {
+		return "", err
+	}
+
+	d, err := layers[0].Digest()
+	if err != nil {
+		return "", err
+	}
+
+	return d.String(), lck.PinFile(path, d.String())
+}
+
+// pinChart pins a chart to its resolved version and the digest of its tarball
+func pinChart(lck *lock.Lock, cfg v1alpha1.Chart, ch *chart.Chart) error {
+	if lck == nil {
+		return nil
+	}
+
+	c, err := ch.Load()
+	if err != nil {
+		return err
+	}
+
+	layers, err := ch.Layers()
+	if err != nil {
+		return err
+	}
+
+	d, err := layers[0].Digest()
+	if err != nil {
+		return err
+	}
+
+	return lck.PinChart(cfg, c.Metadata.Version, d.String())
 }
@@ -0,0 +1,723 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancherfederal/ocil/pkg/store"
+
+	"github.com/rancherfederal/hauler/internal/release/releasetest"
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/auth"
+	"github.com/rancherfederal/hauler/pkg/content"
+	"github.com/rancherfederal/hauler/pkg/lock"
+)
+
+const (
+	imagesDoc = "apiVersion: content.hauler.cattle.io/v1alpha1\nkind: Images\nspec:\n  images:\n  - name: ${image}\n"
+	filesDoc  = "apiVersion: content.hauler.cattle.io/v1alpha1\nkind: Files\nspec:\n  files:\n  - path: https://get.k3s.io\n"
+)
+
+func TestReadDocs(t *testing.T) {
+	ctx := context.Background()
+	vars := content.Variables{"image": "busybox"}
+	rendered := strings.Replace(imagesDoc, "${image}", "busybox", 1)
+
+	local := filepath.Join(t.TempDir(), "contents.yaml")
+	if err := os.WriteFile(local, []byte(imagesDoc+"---\n"+filesDoc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/contents.yaml":
+			fmt.Fprint(w, imagesDoc)
+		case "/broken.yaml":
+			http.Error(w, "<html>internal error</html>", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	reg := httptest.NewServer(registry.New())
+	defer reg.Close()
+	ociRef := strings.TrimPrefix(reg.URL, "http://") + "/hauler/contents:v1"
+	img, err := mutate.AppendLayers(empty.Image,
+		static.NewLayer([]byte(imagesDoc), "application/yaml"),
+		static.NewLayer([]byte(filesDoc), "application/yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(ociRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		stdin   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "should read every document of a local file",
+			source: local,
+			want:   []string{rendered, filesDoc},
+		},
+		{
+			name:   "should read stdin",
+			source: "-",
+			stdin:  imagesDoc,
+			want:   []string{rendered},
+		},
+		{
+			name:   "should fetch an http url",
+			source: srv.URL + "/contents.yaml",
+			want:   []string{rendered},
+		},
+		{
+			name:    "should fail on a missing http url",
+			source:  srv.URL + "/missing.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "should fail on an http server error",
+			source:  srv.URL + "/broken.yaml",
+			wantErr: true,
+		},
+		{
+			name:   "should read every layer of an oci artifact",
+			source: "oci://" + ociRef,
+			want:   []string{rendered, filesDoc},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.source == "-" {
+				stdin := filepath.Join(t.TempDir(), "stdin")
+				if err := os.WriteFile(stdin, []byte(tt.stdin), 0644); err != nil {
+					t.Fatal(err)
+				}
+				f, err := os.Open(stdin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+
+				orig := os.Stdin
+				os.Stdin = f
+				defer func() { os.Stdin = orig }()
+			}
+
+			docs, err := readDocs(ctx, tt.source, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readDocs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got, want []string
+			for _, doc := range docs {
+				got = append(got, strings.TrimSpace(strings.TrimPrefix(string(doc), "---\n")))
+			}
+			for _, doc := range tt.want {
+				want = append(want, strings.TrimSpace(doc))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("readDocs() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParallel(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		n       int
+		fail    []int
+		wantMax int32
+		wantErr string
+	}{
+		{
+			name:    "should run at most limit calls at once",
+			limit:   2,
+			n:       6,
+			wantMax: 2,
+		},
+		{
+			name:    "should run every call at once below the limit",
+			limit:   8,
+			n:       3,
+			wantMax: 3,
+		},
+		{
+			name:    "should run calls one at a time with a zero limit",
+			limit:   0,
+			n:       3,
+			wantMax: 1,
+		},
+		{
+			name:    "should run calls one at a time with a negative limit",
+			limit:   -1,
+			n:       3,
+			wantMax: 1,
+		},
+		{
+			name:  "should do nothing without items",
+			limit: 2,
+		},
+		{
+			name:    "should return a single failure as is",
+			limit:   2,
+			n:       3,
+			fail:    []int{1},
+			wantMax: 2,
+			wantErr: "item 1 failed",
+		},
+		{
+			name:    "should report every failure in index order",
+			limit:   3,
+			n:       5,
+			fail:    []int{4, 0, 2},
+			wantMax: 3,
+			wantErr: "3 items failed: item 0 failed; item 2 failed; item 4 failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fail := make(map[int]bool)
+			for _, i := range tt.fail {
+				fail[i] = true
+			}
+
+			var mu sync.Mutex
+			calls := make(map[int]int)
+			var active, max int32
+			err := parallel(tt.limit, tt.n, func(i int) error {
+				mu.Lock()
+				calls[i]++
+				mu.Unlock()
+
+				cur := atomic.AddInt32(&active, 1)
+				defer atomic.AddInt32(&active, -1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if cur <= m || atomic.CompareAndSwapInt32(&max, m, cur) {
+						break
+					}
+				}
+
+				// Earlier items finish last, so errors are only in index order if parallel orders them
+				time.Sleep(time.Duration(tt.n-i) * 10 * time.Millisecond)
+				if fail[i] {
+					return fmt.Errorf("item %d failed", i)
+				}
+				return nil
+			})
+
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("parallel() error = %q, want %q", gotErr, tt.wantErr)
+			}
+			if max != tt.wantMax {
+				t.Errorf("parallel() ran %d calls at once, want %d", max, tt.wantMax)
+			}
+			if len(calls) != tt.n {
+				t.Errorf("parallel() called %d items, want %d", len(calls), tt.n)
+			}
+			for i, c := range calls {
+				if c != 1 {
+					t.Errorf("parallel() called item %d %d times, want once", i, c)
+				}
+			}
+		})
+	}
+}
+
+func TestContentDir(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{source: "contents.yaml", want: "."},
+		{source: "deploy/contents.yaml", want: "deploy"},
+		{source: "/etc/hauler/contents.yaml", want: "/etc/hauler"},
+		{source: "-", want: ""},
+		{source: "https://example.com/deploy/contents.yaml", want: ""},
+		{source: "oci://registry.example.com/hauler/contents:v1", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := contentDir(tt.source); got != tt.want {
+				t.Errorf("contentDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// pushIndex pushes a multi-platform image to ref with an image for each of platforms
+func pushIndex(t *testing.T, ref string, platforms ...string) {
+	t.Helper()
+
+	var adds []mutate.IndexAddendum
+	for _, s := range platforms {
+		p, err := content.ParsePlatform(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := mutate.ConfigFile(empty.Image, &gv1.ConfigFile{OS: p.OS, Architecture: p.Architecture})
+		if err != nil {
+			t.Fatal(err)
+		}
+		adds = append(adds, mutate.IndexAddendum{Add: img, Descriptor: gv1.Descriptor{Platform: &p}})
+	}
+
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(r, mutate.AppendManifests(empty.Index, adds...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resolveDoc resolves every entry declared by a content document, relative paths are relative to dir
+func resolveDoc(t *testing.T, doc string, dir string) []entry {
+	t.Helper()
+
+	obj, err := content.Load([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolvers, err := resolversFor(context.Background(), obj, []byte(doc), dir, lock.New(), auth.NewKeychain(""), nil, v1alpha1.ChartCapabilities{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []entry
+	for _, r := range resolvers {
+		es, err := r()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, es...)
+	}
+	return entries
+}
+
+func TestRKE2ArchPlatforms(t *testing.T) {
+	srv := releasetest.NewServer(t)
+	image := srv.Registry + "/rancher/rke2-runtime:v1.22.5-rke2r1"
+	pushIndex(t, image, "linux/amd64", "linux/arm64")
+
+	srv.Release("v1.22.5+rke2r1", map[string]string{
+		"rke2-images.linux-arm64.txt": image + "\n",
+		"rke2.linux-arm64.tar.gz":     "tarball",
+		"sha256sum-arm64.txt":         "checksums",
+	})
+
+	doc := fmt.Sprintf(`apiVersion: collection.hauler.cattle.io/v1alpha1
+kind: RKE2
+spec:
+  version: v1.22.5+rke2r1
+  arch: arm64
+  releaseURL: %s
+  channelURL: %s
+  installScriptURL: %s
+`, srv.ReleaseURL(), srv.ChannelURL(), srv.InstallScriptURL())
+
+	var found bool
+	for _, it := range resolveDoc(t, doc, "") {
+		if it.ref != image {
+			continue
+		}
+		found = true
+
+		if it.platformImage == nil {
+			t.Fatalf("image %s isn't restricted to the collection's arch", image)
+		}
+		im, err := it.platformImage.Index.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range im.Manifests {
+			if p := content.FormatPlatform(*m.Platform); p != "linux/arm64" {
+				t.Errorf("image %s holds platform %s, want only linux/arm64", image, p)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("image %s wasn't collected", image)
+	}
+}
+
+func TestUnreachableChannel(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	for _, kind := range []string{"K3s", "RKE2"} {
+		t.Run(kind, func(t *testing.T) {
+			doc := fmt.Sprintf("apiVersion: collection.hauler.cattle.io/v1alpha1\nkind: %s\nspec:\n  version: stable\n  channelURL: %s\n", kind, srv.URL)
+			obj, err := content.Load([]byte(doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lck := lock.New()
+			resolvers, err := resolversFor(context.Background(), obj, []byte(doc), "", lck, auth.NewKeychain(""), nil, v1alpha1.ChartCapabilities{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := resolvers[0](); err == nil {
+				t.Fatal("resolver succeeded with an unreachable channel server")
+			}
+
+			path := filepath.Join(t.TempDir(), lock.DefaultFilename)
+			if err := lck.Save(path); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "stable") {
+				t.Errorf("lock pins the unresolved channel:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestSyncRelativePaths(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	for _, ref := range []string{"library/chart:v1", "library/manifest:v1"} {
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := name.ParseReference(host + "/" + ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(r, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, err := os.ReadFile(pushSigned(t, host+"/library/signed:v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, repo, "init", "-q")
+	gitCmd(t, repo, "commit", "-q", "--allow-empty", "-m", "initial")
+
+	files := map[string]string{
+		"file.txt":                 "hello\n",
+		"password":                 "secret\n",
+		"cosign.pub":               string(key),
+		"chart/Chart.yaml":         "apiVersion: v2\nname: local\nversion: 0.1.0\n",
+		"chart/templates/pod.yaml": "apiVersion: v1\nkind: Pod\nmetadata:\n  name: chart\nspec:\n  containers:\n  - name: app\n    image: {{ .Values.image }}\n",
+		"values.yaml":              "image: " + host + "/library/chart:v1\n",
+		"manifests.yaml":           "apiVersion: v1\nkind: Pod\nmetadata:\n  name: manifest\nspec:\n  containers:\n  - name: app\n    image: " + host + "/library/manifest:v1\n",
+		"contents.yaml": `apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Credentials
+spec:
+  registries:
+  - registry: ` + host + `
+    username: hauler
+    password:
+      file: password
+---
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Files
+spec:
+  files:
+  - path: file.txt
+---
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Gits
+spec:
+  repositories:
+  - url: repo
+---
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Images
+spec:
+  images:
+  - name: ` + host + `/library/signed:v1
+    verify:
+      key: cosign.pub
+---
+apiVersion: collection.hauler.cattle.io/v1alpha1
+kind: ThickCharts
+spec:
+  charts:
+  - name: chart
+    valuesFiles:
+    - values.yaml
+---
+apiVersion: collection.hauler.cattle.io/v1alpha1
+kind: Manifests
+spec:
+  manifests:
+  - path: manifests.yaml
+`,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	s, err := store.NewLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := &SyncOpts{RootOpts: &RootOpts{}, ContentFiles: []string{filepath.Join(dir, "contents.yaml")}, LockFile: lock.DefaultFilename, Concurrency: 1}
+	if err := SyncCmd(context.Background(), o, s); err != nil {
+		t.Fatal(err)
+	}
+
+	var refs []string
+	if err := s.Walk(func(reference string, _ ocispec.Descriptor) error {
+		refs = append(refs, reference)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"hauler/file.txt:latest",
+		"hauler/repo:latest",
+		host + "/library/signed:v1",
+		"hauler/local:0.1.0",
+		host + "/library/chart:v1",
+		"hauler/manifests.yaml:latest",
+		host + "/library/manifest:v1",
+	} {
+		if !contains(refs, want) {
+			t.Errorf("store references %v, missing %s", refs, want)
+		}
+	}
+
+	lck, err := lock.Load(lock.DefaultFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lck.Files) != 1 || lck.Files[0].Path != "file.txt" {
+		t.Errorf("locked files = %v, want file.txt pinned as declared", lck.Files)
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=hauler", "GIT_AUTHOR_EMAIL=hauler@example.com",
+		"GIT_COMMITTER_NAME=hauler", "GIT_COMMITTER_EMAIL=hauler@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestSyncSkipsUnchanged(t *testing.T) {
+	const data = "#!/bin/sh\necho hello\n"
+	sum := sha256.Sum256([]byte(data))
+
+	// Files are named from a HEAD request, only downloads are fetches
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&fetches, 1)
+		}
+		w.Write([]byte(data))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, repo, "init", "-q")
+	gitCmd(t, repo, "commit", "-q", "--allow-empty", "-m", "initial")
+
+	contents := filepath.Join(dir, "contents.yaml")
+	doc := `apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Files
+spec:
+  files:
+  - path: ` + srv.URL + `/checksummed.sh
+    sha256: ` + hex.EncodeToString(sum[:]) + `
+  - path: ` + srv.URL + `/pinned.sh
+---
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Gits
+spec:
+  repositories:
+  - url: repo
+`
+	if err := os.WriteFile(contents, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := store.NewLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &SyncOpts{RootOpts: &RootOpts{}, ContentFiles: []string{contents}, LockFile: filepath.Join(dir, lock.DefaultFilename), Concurrency: 1}
+	if err := SyncCmd(context.Background(), o, s); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&fetches) == 0 {
+		t.Fatal("first sync fetched no files")
+	}
+	stored := storeIndex(t, s)
+
+	// The repository's refs still list, but it can no longer be cloned
+	objects, err := filepath.Glob(filepath.Join(repo, ".git", "objects", "??"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range objects {
+		if err := os.RemoveAll(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	atomic.StoreInt32(&fetches, 0)
+	o.Locked = true
+	if err := SyncCmd(context.Background(), o, s); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 0 {
+		t.Errorf("second sync fetched %d files, want none", n)
+	}
+	if got := storeIndex(t, s); !reflect.DeepEqual(got, stored) {
+		t.Errorf("second sync changed the store from %v to %v", stored, got)
+	}
+}
+
+// storeIndex returns the digest stored at every reference of a store
+func storeIndex(t *testing.T, s *store.Layout) map[string]string {
+	t.Helper()
+
+	refs := make(map[string]string)
+	if err := s.Walk(func(reference string, desc ocispec.Descriptor) error {
+		refs[reference] = desc.Digest.String()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return refs
+}
+
+func TestImageTags(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	repo := strings.TrimPrefix(srv.URL, "http://") + "/library/app"
+
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "sha256-abc.sig"} {
+		r, err := name.ParseReference(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(r, empty.Image); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := v1alpha1.ImageRepository{Name: repo}
+	want := []string{"v1.1.0", "v1.0.0"}
+
+	l := lock.New()
+	tags, err := imageTags(cfg, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("imageTags() = %v, want %v", tags, want)
+	}
+
+	// An image of the same repository declared on its own isn't one of the repository's tags
+	if err := l.PinImage(repo+":v0.9.0", "sha256:aaa"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), lock.DefaultFilename)
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := lock.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err = imageTags(cfg, locked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("locked imageTags() = %v, want %v", tags, want)
+	}
+}
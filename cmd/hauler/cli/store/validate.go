@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+	"github.com/rancherfederal/hauler/pkg/log"
+)
+
+type ValidateOpts struct {
+	*RootOpts
+	ContentFiles []string
+	Schema       bool
+	Set          []string
+	ValuesFiles  []string
+}
+
+func (o *ValidateOpts) AddFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+
+	f.StringSliceVarP(&o.ContentFiles, "files", "f", []string{}, "Path to content files, can be a local path, an http(s) url, an oci:// reference, or - for stdin")
+	f.StringArrayVar(&o.Set, "set", []string{}, "Set a variable referenced by the content files as ${key} (can specify multiple, key=value)")
+	f.StringSliceVar(&o.ValuesFiles, "values", []string{}, "Path to yaml files of variables referenced by the content files")
+	f.BoolVar(&o.Schema, "schema", false, "Print the JSON Schema of the content and collection types instead of validating")
+}
+
+func ValidateCmd(ctx context.Context, o *ValidateOpts) error {
+	l := log.FromContext(ctx)
+
+	if o.Schema {
+		data, err := content.Schema()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	vars, err := variables(o.Set, o.ValuesFiles)
+	if err != nil {
+		return err
+	}
+
+	var problems int
+	for _, filename := range o.ContentFiles {
+		docs, err := readDocs(ctx, filename, vars, remote.WithAuthFromKeychain(o.Keychain()))
+		if err != nil {
+			return err
+		}
+
+		for i, doc := range docs {
+			err := content.Validate(doc)
+
+			var verr content.ValidationError
+			switch {
+			case err == nil:
+				continue
+
+			case errors.As(err, &verr):
+				for _, fe := range verr {
+					l.Errorf("%s: document %d: %s", filename, i+1, fe)
+				}
+				problems += len(verr)
+
+			default:
+				l.Errorf("%s: document %d: %s", filename, i+1, err)
+				problems++
+			}
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problems in content files", problems)
+	}
+
+	l.Infof("content files are valid")
+	return nil
+}
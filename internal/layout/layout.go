@@ -0,0 +1,320 @@
+// Package layout provides operations on a store's oci layout that the store itself doesn't expose
+package layout
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	gtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"github.com/rancherfederal/ocil/pkg/consts"
+	"github.com/rancherfederal/ocil/pkg/content"
+	"github.com/rancherfederal/ocil/pkg/store"
+)
+
+// ErrDigestMismatch is returned when the contents written for a layer don't match the layer's digest
+var ErrDigestMismatch = errors.New("layer contents do not match digest")
+
+// Prune removes every reference that isn't kept from the store's index, and deletes any blobs no longer referenced by
+// the remaining references.  The removed references are returned.
+func Prune(ctx context.Context, s *store.Layout, keep map[string]bool) ([]string, error) {
+	idx, err := readIndex(s)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var (
+		kept    []ocispec.Descriptor
+		removed []string
+	)
+	for _, desc := range idx.Manifests {
+		ref := desc.Annotations[ocispec.AnnotationRefName]
+		if keep[ref] {
+			kept = append(kept, desc)
+			continue
+		}
+		removed = append(removed, ref)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	idx.Manifests = kept
+	if err := writeIndex(s, idx); err != nil {
+		return nil, err
+	}
+
+	return removed, collectGarbage(ctx, s)
+}
+
+// SortIndex orders the store's index by reference, the store itself writes its index in no particular order
+func SortIndex(s *store.Layout) error {
+	idx, err := readIndex(s)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	sort.Slice(idx.Manifests, func(i, j int) bool {
+		return idx.Manifests[i].Annotations[ocispec.AnnotationRefName] < idx.Manifests[j].Annotations[ocispec.AnnotationRefName]
+	})
+	return writeIndex(s, idx)
+}
+
+// WriteLayers writes an artifact's layers to the store's blobs, skipping any that already exist.  Unlike adding an
+// artifact to the store, it is safe to call concurrently, which lets artifacts be fetched in parallel before they're
+// added to the store.
+func WriteLayers(s *store.Layout, oci artifacts.OCI) error {
+	layers, err := oci.Layers()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range layers {
+		if err := writeLayer(s, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteIndex writes an image index and every manifest, config and layer it references to the store's blobs, skipping
+// any that already exist.  Like WriteLayers, it is safe to call concurrently.  The index itself still needs to be
+// added to the store's index by its returned descriptor.
+func WriteIndex(s *store.Layout, idx gv1.ImageIndex) (ocispec.Descriptor, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	for _, desc := range im.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			if _, err := WriteIndex(s, child); err != nil {
+				return ocispec.Descriptor{}, err
+			}
+
+		case desc.MediaType.IsImage():
+			img, err := idx.Image(desc.Digest)
+			if err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			if err := writeImage(s, img); err != nil {
+				return ocispec.Descriptor{}, err
+			}
+		}
+	}
+
+	raw, err := idx.RawManifest()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err := writeLayer(s, static.NewLayer(raw, "")); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	mt, err := idx.MediaType()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return ocispec.Descriptor{
+		MediaType: string(mt),
+		Digest:    digest.FromBytes(raw),
+		Size:      int64(len(raw)),
+	}, nil
+}
+
+func writeImage(s *store.Layout, img gv1.Image) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		if err := writeLayer(s, l); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := writeLayer(s, static.NewLayer(cfg, "")); err != nil {
+		return err
+	}
+
+	raw, err := img.RawManifest()
+	if err != nil {
+		return err
+	}
+	return writeLayer(s, static.NewLayer(raw, ""))
+}
+
+func writeLayer(s *store.Layout, l gv1.Layer) error {
+	d, err := l.Digest()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(s.Root, "blobs", d.Algorithm)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	blobPath := filepath.Join(dir, d.Hex)
+	if _, err := os.Stat(blobPath); err == nil {
+		return nil
+	}
+
+	rc, err := l.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Write to a temporary file first so a partially written blob is never mistaken for an existing one
+	tmp, err := os.CreateTemp(dir, d.Hex+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// Layers of remote sources are fetched again to be written, so what's written is verified against the digest the
+	// layer was described (and possibly verified or pinned) with rather than trusted to be the same
+	h, err := gv1.Hasher(d.Algorithm)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(io.MultiWriter(tmp, h), rc); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	written := gv1.Hash{Algorithm: d.Algorithm, Hex: hex.EncodeToString(h.Sum(nil))}
+	if written != d {
+		return fmt.Errorf("%w: wrote %s, want %s", ErrDigestMismatch, written, d)
+	}
+	return os.Rename(tmp.Name(), blobPath)
+}
+
+func readIndex(s *store.Layout) (ocispec.Index, error) {
+	var idx ocispec.Index
+
+	data, err := os.ReadFile(filepath.Join(s.Root, consts.OCIImageIndexFile))
+	if err != nil {
+		return idx, err
+	}
+
+	err = json.Unmarshal(data, &idx)
+	return idx, err
+}
+
+// writeIndex replaces the store's index, the store only ever adds references to its in memory index so it is swapped
+// for one loaded from the new index
+func writeIndex(s *store.Layout, idx ocispec.Index) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.Root, consts.OCIImageIndexFile), data, 0644); err != nil {
+		return err
+	}
+
+	o, err := content.NewOCI(s.Root)
+	if err != nil {
+		return err
+	}
+	if err := o.LoadIndex(); err != nil {
+		return err
+	}
+	s.OCI = o
+	return nil
+}
+
+// collectGarbage deletes every blob that isn't reachable from a reference in the store's index
+func collectGarbage(ctx context.Context, s *store.Layout) error {
+	reachable := make(map[digest.Digest]bool)
+	if err := s.Walk(func(reference string, desc ocispec.Descriptor) error {
+		return mark(ctx, s, desc, reachable)
+	}); err != nil {
+		return err
+	}
+
+	blobs := filepath.Join(s.Root, "blobs")
+	return filepath.WalkDir(blobs, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		alg := filepath.Base(filepath.Dir(path))
+		if reachable[digest.NewDigestFromEncoded(digest.Algorithm(alg), d.Name())] {
+			return nil
+		}
+		return os.Remove(path)
+	})
+}
+
+// mark marks a descriptor and everything it references as reachable
+func mark(ctx context.Context, s *store.Layout, desc ocispec.Descriptor, reachable map[digest.Digest]bool) error {
+	reachable[desc.Digest] = true
+
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, consts.DockerManifestSchema2,
+		ocispec.MediaTypeImageIndex, string(gtypes.DockerManifestList):
+	default:
+		return nil
+	}
+
+	rc, err := s.Fetch(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Manifests and indexes are decoded together, only the fields present for the media type are populated
+	var m struct {
+		Config    ocispec.Descriptor   `json:"config"`
+		Layers    []ocispec.Descriptor `json:"layers"`
+		Manifests []ocispec.Descriptor `json:"manifests"`
+	}
+	if err := json.NewDecoder(rc).Decode(&m); err != nil {
+		return err
+	}
+
+	if m.Config.Digest != "" {
+		reachable[m.Config.Digest] = true
+	}
+	for _, l := range m.Layers {
+		reachable[l.Digest] = true
+	}
+	for _, child := range m.Manifests {
+		if err := mark(ctx, s, child, reachable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package layout_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"github.com/rancherfederal/ocil/pkg/artifacts/memory"
+	"github.com/rancherfederal/ocil/pkg/layer"
+	"github.com/rancherfederal/ocil/pkg/store"
+
+	"github.com/rancherfederal/hauler/internal/layout"
+)
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+
+	tmpdir, err := os.MkdirTemp("", "hauler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	s, err := store.NewLayout(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keepData := []byte("keep")
+	pruneData := []byte("prune")
+	if _, err := s.AddOCI(ctx, memory.NewMemory(keepData, "text/plain"), "hauler/keep:latest"); err != nil {
+		t.Fatal(err)
+	}
+	pruneDesc, err := s.AddOCI(ctx, memory.NewMemory(pruneData, "text/plain"), "hauler/prune:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := layout.Prune(ctx, s, map[string]bool{"hauler/keep:latest": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"hauler/prune:latest"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Prune() removed = %v, want %v", removed, want)
+	}
+
+	var refs []string
+	if err := s.Walk(func(reference string, desc ocispec.Descriptor) error {
+		refs = append(refs, reference)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"hauler/keep:latest"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("store references = %v, want %v", refs, want)
+	}
+
+	pruned := filepath.Join(tmpdir, "blobs", pruneDesc.Digest.Algorithm().String(), pruneDesc.Digest.Hex())
+	if _, err := os.Stat(pruned); !os.IsNotExist(err) {
+		t.Errorf("expected pruned manifest blob to be removed, got %v", err)
+	}
+
+	_, keepDesc, err := s.Resolve(ctx, "hauler/keep:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(tmpdir, "blobs", keepDesc.Digest.Algorithm().String(), keepDesc.Digest.Hex())
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("expected kept manifest blob to remain, got %v", err)
+	}
+}
+
+func TestWriteLayersVerifiesDigest(t *testing.T) {
+	tmpdir := t.TempDir()
+	s, err := store.NewLayout(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The layer is described from its first read, and written from the next
+	opens := 0
+	l, err := layer.FromOpener(func() (io.ReadCloser, error) {
+		opens++
+		if opens == 1 {
+			return io.NopCloser(strings.NewReader("original")), nil
+		}
+		return io.NopCloser(strings.NewReader("tampered")), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := l.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oci := memory.NewMemory([]byte("config"), "text/plain")
+	if err := layout.WriteLayers(s, &layers{OCI: oci, layers: []gv1.Layer{l}}); !errors.Is(err, layout.ErrDigestMismatch) {
+		t.Fatalf("WriteLayers() error = %v, want %v", err, layout.ErrDigestMismatch)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(tmpdir, "blobs", d.Algorithm))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("unexpected blob %s left in the store", e.Name())
+	}
+}
+
+// layers overrides the layers of an artifact
+type layers struct {
+	artifacts.OCI
+	layers []gv1.Layer
+}
+
+func (l *layers) Layers() ([]gv1.Layer, error) {
+	return l.layers, nil
+}
@@ -16,11 +16,17 @@ import (
 
 // NewMapperFileStore creates a new file store that uses mapper functions for each detected descriptor.
 // 		This extends content.File, and differs in that it allows much more functionality into how each descriptor is written.
-func NewMapperFileStore(root string, mapper map[string]Fn) *store {
+// 		Descriptors of the unpacked media types are tar+gzip archives, extracted into the directory named by their mapper.
+func NewMapperFileStore(root string, mapper map[string]Fn, unpacked ...string) *store {
 	fs := content.NewFile(root)
+	unpack := make(map[string]bool, len(unpacked))
+	for _, mt := range unpacked {
+		unpack[mt] = true
+	}
 	return &store{
 		File:   fs,
 		mapper: mapper,
+		unpack: unpack,
 	}
 }
 
@@ -38,15 +44,30 @@ func (s *store) Pusher(ctx context.Context, ref string) (remotes.Pusher, error)
 		tag:    tag,
 		ref:    hash,
 		mapper: s.mapper,
+		unpack: s.unpack,
 	}, nil
 }
 
 type store struct {
 	*content.File
 	mapper map[string]Fn
+	unpack map[string]bool
 }
 
 func (s *pusher) Push(ctx context.Context, desc ocispec.Descriptor) (ccontent.Writer, error) {
+	// Unpacked descriptors are named by their mapper even when they carry a name of their own
+	if _, ok := s.mapper[desc.MediaType]; ok && s.unpack[desc.MediaType] {
+		dir, err := s.mapper[desc.MediaType](desc)
+		if err != nil {
+			return nil, err
+		}
+		path, err := within(s.store.ResolvePath(""), dir)
+		if err != nil {
+			return nil, err
+		}
+		return newUnpackWriter(path, desc)
+	}
+
 	// TODO: This is suuuuuper ugly... redo this when oras v2 is out
 	if _, ok := content.ResolveName(desc); ok {
 		p, err := s.store.Pusher(ctx, s.ref)
@@ -82,4 +103,5 @@ type pusher struct {
 	tag    string
 	ref    string
 	mapper map[string]Fn
+	unpack map[string]bool
 }
@@ -7,6 +7,8 @@ import (
 	"oras.land/oras-go/pkg/target"
 
 	"github.com/rancherfederal/ocil/pkg/consts"
+
+	hcontent "github.com/rancherfederal/hauler/pkg/content"
 )
 
 type Fn func(desc ocispec.Descriptor) (string, error)
@@ -25,6 +27,16 @@ func FromManifest(manifest ocispec.Manifest, root string) (target.Target, error)
 		defer s.Close()
 		return s, nil
 
+	case hcontent.GitConfigMediaType:
+		s := NewMapperFileStore(root, Git())
+		defer s.Close()
+		return s, nil
+
+	case consts.FileDirectoryConfigMediaType:
+		s := NewMapperFileStore(root, Directory(), hcontent.DirectoryLayerMediaType)
+		defer s.Close()
+		return s, nil
+
 	default:
 		s := NewMapperFileStore(root, nil)
 		defer s.Close()
@@ -74,10 +86,46 @@ func Chart() map[string]Fn {
 	})
 
 	provMapperFn := Fn(func(desc ocispec.Descriptor) (string, error) {
-		return "prov.json", nil
+		f := "chart.tar.gz.prov"
+		if _, ok := desc.Annotations[ocispec.AnnotationTitle]; ok {
+			f = desc.Annotations[ocispec.AnnotationTitle]
+		}
+		return f, nil
 	})
 
 	m[consts.ChartLayerMediaType] = chartMapperFn
 	m[consts.ProvLayerMediaType] = provMapperFn
 	return m
 }
+
+// Git maps a repository's bundle to a file named after its title
+func Git() map[string]Fn {
+	m := make(map[string]Fn)
+
+	bundleMapperFn := Fn(func(desc ocispec.Descriptor) (string, error) {
+		f := "repository.bundle"
+		if _, ok := desc.Annotations[ocispec.AnnotationTitle]; ok {
+			f = desc.Annotations[ocispec.AnnotationTitle]
+		}
+		return f, nil
+	})
+
+	m[hcontent.GitBundleLayerMediaType] = bundleMapperFn
+	return m
+}
+
+// Directory maps directory layers to the directory they're unpacked into, named after their title
+func Directory() map[string]Fn {
+	m := make(map[string]Fn)
+
+	dirMapperFn := Fn(func(desc ocispec.Descriptor) (string, error) {
+		d, ok := desc.Annotations[ocispec.AnnotationTitle]
+		if !ok || d == "" {
+			return "", fmt.Errorf("directory layer %s has no name", desc.Digest)
+		}
+		return d, nil
+	})
+
+	m[hcontent.DirectoryLayerMediaType] = dirMapperFn
+	return m
+}
@@ -0,0 +1,202 @@
+package mapper
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ccontent "github.com/containerd/containerd/content"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/pkg/content"
+)
+
+// unpackWriter buffers a tar+gzip archive to a temporary file, extracting it into dir once it's committed
+type unpackWriter struct {
+	ccontent.Writer
+
+	tmp *os.File
+	dir string
+}
+
+func newUnpackWriter(dir string, desc ocispec.Descriptor) (ccontent.Writer, error) {
+	tmp, err := os.CreateTemp("", "hauler")
+	if err != nil {
+		return nil, err
+	}
+
+	w := content.NewIoContentWriter(tmp, content.WithInputHash(desc.Digest), content.WithOutputHash(desc.Digest))
+	return &unpackWriter{Writer: w, tmp: tmp, dir: dir}, nil
+}
+
+func (w *unpackWriter) Commit(ctx context.Context, size int64, expected digest.Digest, opts ...ccontent.Opt) error {
+	defer w.cleanup()
+	if err := w.Writer.Commit(ctx, size, expected, opts...); err != nil {
+		return err
+	}
+
+	if _, err := w.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return untar(w.tmp, w.dir)
+}
+
+func (w *unpackWriter) Close() error {
+	err := w.Writer.Close()
+	w.cleanup()
+	return err
+}
+
+func (w *unpackWriter) cleanup() {
+	w.tmp.Close()
+	os.Remove(w.tmp.Name())
+}
+
+// untar extracts a tar+gzip archive into dir, restoring the modes of its entries.  Entries that would be written
+// outside of dir are refused, including entries written through a symlink and symlinks resolving outside of dir.
+func untar(r io.Reader, dir string) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path, err := within(root, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err := noSymlinks(root, filepath.Dir(path)); err != nil {
+			return fmt.Errorf("%q: %w", hdr.Name, err)
+		}
+
+		mode := hdr.FileInfo().Mode()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := noSymlinks(root, path); err != nil {
+				return fmt.Errorf("%q: %w", hdr.Name, err)
+			}
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			if err := os.Chmod(path, mode.Perm()); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(path, tr, mode.Perm()); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			target := hdr.Linkname
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(hdr.Name), target)
+			}
+			if _, err := within(root, target); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+
+			// A target within dir by name can still resolve outside of it through other links, like a/.. when a is .
+			if resolved, err := filepath.EvalSymlinks(path); err == nil {
+				if _, err := within(root, relative(root, resolved)); err != nil {
+					os.Remove(path)
+					return fmt.Errorf("link %q resolves outside of %q", hdr.Name, dir)
+				}
+			}
+
+		default:
+			// Other entries, like devices, are never archived
+		}
+	}
+}
+
+// within returns the path of name in dir, or an error when it resolves outside of dir
+func within(dir string, name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is outside of %q", name, dir)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+// relative returns path relative to dir, or path itself when it can't be made relative
+func relative(dir string, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// noSymlinks returns an error when path, or any of its parents below dir, is an existing symlink, so that nothing is
+// ever written through a link extracted earlier
+func noSymlinks(dir string, path string) error {
+	for p := path; p != dir && strings.HasPrefix(p, dir); p = filepath.Dir(p) {
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("path crosses the symlink %q", relative(dir, p))
+		}
+	}
+	return nil
+}
+
+// writeFile writes a new file at path, replacing whatever was there without following it when it's a link
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
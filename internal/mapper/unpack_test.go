@@ -0,0 +1,125 @@
+package mapper
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUntar(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tar.Header
+		want    map[string]os.FileMode
+		wantErr bool
+
+		// outside are paths beside the directory that must not be written
+		outside []string
+	}{
+		{
+			name: "should restore the tree and its modes",
+			entries: []tar.Header{
+				{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0750},
+				{Name: "bin/run.sh", Typeflag: tar.TypeReg, Mode: 0755},
+				{Name: "config.yaml", Typeflag: tar.TypeReg, Mode: 0600},
+				{Name: "latest", Typeflag: tar.TypeSymlink, Linkname: "config.yaml"},
+			},
+			want: map[string]os.FileMode{
+				"bin":         0750 | os.ModeDir,
+				"bin/run.sh":  0755,
+				"config.yaml": 0600,
+				"latest":      0777 | os.ModeSymlink,
+			},
+		},
+		{
+			name:    "should refuse entries outside of the directory",
+			entries: []tar.Header{{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0644}},
+			wantErr: true,
+		},
+		{
+			name:    "should refuse links outside of the directory",
+			entries: []tar.Header{{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "../../etc/passwd"}},
+			wantErr: true,
+		},
+		{
+			name: "should refuse entries written through chained links",
+			entries: []tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				{Name: "b/x", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			wantErr: true,
+			outside: []string{"b", "x"},
+		},
+		{
+			name: "should refuse links resolving outside of the directory through other links",
+			entries: []tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "p", Typeflag: tar.TypeSymlink, Linkname: "a/.."},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should refuse directories written through links",
+			entries: []tar.Header{
+				{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "l", Typeflag: tar.TypeSymlink, Linkname: "sub"},
+				{Name: "l/x", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should replace links with files rather than write through them",
+			entries: []tar.Header{
+				{Name: "config.yaml", Typeflag: tar.TypeReg, Mode: 0600},
+				{Name: "latest", Typeflag: tar.TypeSymlink, Linkname: "config.yaml"},
+				{Name: "latest", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			want: map[string]os.FileMode{
+				"config.yaml": 0600,
+				"latest":      0644,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			zw := gzip.NewWriter(buf)
+			tw := tar.NewWriter(zw)
+			for _, hdr := range tt.entries {
+				hdr := hdr
+				if err := tw.WriteHeader(&hdr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw.Close()
+			zw.Close()
+
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "out")
+			err := untar(buf, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("untar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, name := range tt.outside {
+				if _, err := os.Lstat(filepath.Join(parent, name)); err == nil {
+					t.Errorf("%s was written outside of the directory", name)
+				}
+			}
+
+			for name, mode := range tt.want {
+				fi, err := os.Lstat(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode() != mode {
+					t.Errorf("%s mode = %s, want %s", name, fi.Mode(), mode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Package release fetches the artifacts and channels of upstream rancher, rke2 and k3s releases
+package release
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"time"
+)
+
+// DefaultTimeout bounds every request of the default client, so an unresponsive release server fails a sync rather
+// than hanging it
+const DefaultTimeout = time.Minute
+
+// ErrChannelNotFound is returned when a version is neither a release nor the name of a channel
+var ErrChannelNotFound = errors.New("channel not found")
+
+// releaseVersion matches releases (v1.22.5+k3s1), unlike the names of channels (stable, v1.22)
+var releaseVersion = regexp.MustCompile(`^v\d+\.\d+\.\d+`)
+
+// DefaultClient is used for collections that aren't given a client
+var DefaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// Client returns c, or the default client when c is nil
+func Client(c *http.Client) *http.Client {
+	if c == nil {
+		return DefaultClient
+	}
+	return c
+}
+
+// Endpoints locates the releases, channels and install script of a distribution
+type Endpoints struct {
+	Release       string
+	Channel       string
+	InstallScript string
+
+	// Client makes every request to the endpoints, DefaultClient when nil
+	Client *http.Client
+}
+
+// WithDefaults returns the endpoints with every one that's empty taken from defaults
+func (e Endpoints) WithDefaults(defaults Endpoints) Endpoints {
+	if e.Release == "" {
+		e.Release = defaults.Release
+	}
+	if e.Channel == "" {
+		e.Channel = defaults.Channel
+	}
+	if e.InstallScript == "" {
+		e.InstallScript = defaults.InstallScript
+	}
+	if e.Client == nil {
+		e.Client = defaults.Client
+	}
+	return e
+}
+
+// ReleaseURL returns the url of an artifact of the release version
+func (e Endpoints) ReleaseURL(version string, artifact string) string {
+	u, _ := url.Parse(e.Release)
+	u.Path = path.Join(u.Path, version, artifact)
+	return u.String()
+}
+
+// ResolveVersion returns the latest release of the channel named by version.  Versions that are releases already are
+// returned unchanged, without contacting the channel server.
+func ResolveVersion(version string, e Endpoints) (string, error) {
+	if releaseVersion.MatchString(version) {
+		return version, nil
+	}
+
+	channels, err := Channels(e.Client, e.Channel)
+	if err != nil {
+		return "", fmt.Errorf("resolve channel %s: %w", version, err)
+	}
+
+	latest, ok := channels[version]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrChannelNotFound, version)
+	}
+	return latest, nil
+}
+
+// Exists checks that an artifact is available at u without downloading it
+func Exists(c *http.Client, u string) error {
+	resp, err := Client(c).Head(u)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return nil
+}
+
+// Get returns the contents of the artifact at u, which the caller closes
+func Get(c *http.Client, u string) (io.ReadCloser, error) {
+	resp, err := Client(c).Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Channels returns the latest release of every channel served by a channel server at u
+func Channels(c *http.Client, u string) (map[string]string, error) {
+	rc, err := Get(c, u)
+	if err != nil {
+		return nil, fmt.Errorf("fetch channels: %w", err)
+	}
+	defer rc.Close()
+
+	var ch channels
+	if err := json.NewDecoder(rc).Decode(&ch); err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]string)
+	for _, d := range ch.Data {
+		latest[d.Name] = d.Latest
+	}
+	return latest, nil
+}
+
+type channels struct {
+	Data []channelData `json:"data"`
+}
+
+type channelData struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Latest string `json:"latest"`
+}
@@ -0,0 +1,136 @@
+package release_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancherfederal/hauler/internal/release"
+	"github.com/rancherfederal/hauler/internal/release/releasetest"
+)
+
+// errUnreachable stands for any error reaching a server
+var errUnreachable = errors.New("unreachable")
+
+func TestResolveVersion(t *testing.T) {
+	srv := releasetest.NewServer(t)
+	srv.Channels(map[string]string{"stable": "v1.22.5+rke2r1", "latest": "v1.23.1+rke2r2", "v1.21": "v1.21.8+rke2r2"})
+
+	// A channel server that can't be reached
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	unreachable := release.Endpoints{Channel: down.URL}
+
+	tests := []struct {
+		name      string
+		version   string
+		endpoints release.Endpoints
+		want      string
+		wantErr   error
+	}{
+		{name: "should resolve a channel", version: "stable", endpoints: srv.Endpoints(), want: "v1.22.5+rke2r1"},
+		{name: "should resolve another channel", version: "latest", endpoints: srv.Endpoints(), want: "v1.23.1+rke2r2"},
+		{name: "should resolve a minor version channel", version: "v1.21", endpoints: srv.Endpoints(), want: "v1.21.8+rke2r2"},
+		{name: "should keep a release", version: "v1.21.8+rke2r2", endpoints: srv.Endpoints(), want: "v1.21.8+rke2r2"},
+		{name: "should keep a release without reaching the channel server", version: "v1.21.8+rke2r2", endpoints: unreachable, want: "v1.21.8+rke2r2"},
+		{name: "should fail on an unknown channel", version: "stabel", endpoints: srv.Endpoints(), wantErr: release.ErrChannelNotFound},
+		{name: "should fail when the channel server can't be reached", version: "stable", endpoints: unreachable, wantErr: errUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := release.ResolveVersion(tt.version, tt.endpoints)
+			switch {
+			case tt.wantErr == errUnreachable:
+				if err == nil {
+					t.Fatalf("ResolveVersion() = %s, want an error", got)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("ResolveVersion() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	client := &http.Client{}
+	defaults := release.Endpoints{
+		Release:       "https://example.com/releases",
+		Channel:       "https://example.com/channels",
+		InstallScript: "https://example.com/install.sh",
+		Client:        client,
+	}
+
+	got := release.Endpoints{Release: "http://mirror.local/releases"}.WithDefaults(defaults)
+	if got.Release != "http://mirror.local/releases" || got.Channel != defaults.Channel || got.InstallScript != defaults.InstallScript || got.Client != client {
+		t.Errorf("WithDefaults() = %+v", got)
+	}
+
+	if u := got.ReleaseURL("v1.22.5+k3s1", "k3s"); u != "http://mirror.local/releases/v1.22.5+k3s1/k3s" {
+		t.Errorf("ReleaseURL() = %s", u)
+	}
+}
+
+func TestClient(t *testing.T) {
+	// A release server that never answers
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := &http.Client{Timeout: 50 * time.Millisecond}
+	e := release.Endpoints{Release: srv.URL, Channel: srv.URL, Client: c}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "Exists",
+			call: func() error { return release.Exists(c, srv.URL) },
+		},
+		{
+			name: "Get",
+			call: func() error {
+				rc, err := release.Get(c, srv.URL)
+				if err == nil {
+					rc.Close()
+				}
+				return err
+			},
+		},
+		{
+			name: "Channels",
+			call: func() error {
+				_, err := release.Channels(c, srv.URL)
+				return err
+			},
+		},
+		{
+			name: "ResolveVersion",
+			call: func() error {
+				_, err := release.ResolveVersion("stable", e)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan error, 1)
+			go func() { done <- tt.call() }()
+
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Errorf("%s() succeeded, want a timeout", tt.name)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s() didn't time out", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package releasetest serves upstream releases and their images for tests
+package releasetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/rancherfederal/hauler/internal/release"
+)
+
+// Server serves release artifacts under ReleaseURL, channels at ChannelURL and an install script at InstallScriptURL,
+// along with a registry at Registry holding the images of the releases
+type Server struct {
+	URL      string
+	Registry string
+
+	mux *http.ServeMux
+}
+
+// NewServer returns a server without any releases, closed when the test ends
+func NewServer(t *testing.T) *Server {
+	reg := httptest.NewServer(registry.New())
+	t.Cleanup(reg.Close)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/install.sh", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#!/bin/sh\n")
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return &Server{
+		URL:      srv.URL,
+		Registry: strings.TrimPrefix(reg.URL, "http://"),
+		mux:      mux,
+	}
+}
+
+// NewRelease returns a server serving a release of version: the image list named list, listing images pushed to the
+// registry, and each of artifacts.  The references of the images in the registry are returned along with it.
+func NewRelease(t *testing.T, version string, list string, images []string, artifacts ...string) (*Server, []string) {
+	s := NewServer(t)
+	refs := s.Images(t, images...)
+
+	data := map[string]string{list: strings.Join(refs, "\n") + "\n"}
+	for _, artifact := range artifacts {
+		data[artifact] = artifact
+	}
+	s.Release(version, data)
+	return s, refs
+}
+
+// Endpoints returns the endpoints of the server
+func (s *Server) Endpoints() release.Endpoints {
+	return release.Endpoints{
+		Release:       s.ReleaseURL(),
+		Channel:       s.ChannelURL(),
+		InstallScript: s.InstallScriptURL(),
+	}
+}
+
+func (s *Server) ReleaseURL() string {
+	return s.URL + "/releases"
+}
+
+func (s *Server) ChannelURL() string {
+	return s.URL + "/channels"
+}
+
+func (s *Server) InstallScriptURL() string {
+	return s.URL + "/install.sh"
+}
+
+// Images pushes an empty image to the registry for each of images, returning their references in the registry
+func (s *Server) Images(t *testing.T, images ...string) []string {
+	refs := make([]string, len(images))
+	for i, image := range images {
+		refs[i] = s.Registry + "/" + image
+
+		ref, err := name.ParseReference(refs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, empty.Image); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return refs
+}
+
+// Release serves the artifacts of a release, by name
+func (s *Server) Release(version string, artifacts map[string]string) {
+	for artifact, data := range artifacts {
+		data := data
+		s.mux.HandleFunc("/releases/"+version+"/"+artifact, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, data)
+		})
+	}
+}
+
+// Channels serves the latest release of each channel
+func (s *Server) Channels(latest map[string]string) {
+	type channel struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Latest string `json:"latest"`
+	}
+
+	var data []channel
+	for name, version := range latest {
+		data = append(data, channel{ID: name, Name: name, Latest: version})
+	}
+
+	s.mux.HandleFunc("/channels", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	})
+}
+
+// Handle serves anything else a release needs, like a chart repository
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
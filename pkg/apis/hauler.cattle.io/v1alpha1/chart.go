@@ -7,6 +7,9 @@ import (
 const (
 	ChartsContentKind    = "Charts"
 	ChartsCollectionKind = "ThickCharts"
+
+	// ChartVersionsRange selects every chart version matching the version constraint, instead of the newest
+	ChartVersionsRange = "range"
 )
 
 type Charts struct {
@@ -24,6 +27,18 @@ type Chart struct {
 	Name    string `json:"name,omitempty"`
 	RepoURL string `json:"repoURL,omitempty"`
 	Version string `json:"version,omitempty"`
+
+	// Versions is "range" to select every version matching Version, by default only the newest version is selected
+	Versions string `json:"versions,omitempty"`
+
+	// MaxVersions only selects the newest versions of a range, when greater than zero
+	MaxVersions int `json:"maxVersions,omitempty"`
+
+	// Verify requires the chart to be signed, verifying it against Keyring and storing its provenance file with it
+	Verify bool `json:"verify,omitempty"`
+
+	// Keyring is the path to the public keys charts are verified against, defaulting to gpg's public keyring
+	Keyring string `json:"keyring,omitempty"`
 }
 
 type ThickCharts struct {
@@ -40,6 +55,48 @@ type ThickChartSpec struct {
 type ThickChart struct {
 	Chart       `json:",inline,omitempty"`
 	ExtraImages []ChartImage `json:"extraImages,omitempty"`
+
+	// ImagePaths are JSONPaths to images in the rendered chart, in addition to the well known paths hauler recognizes
+	ImagePaths []string `json:"imagePaths,omitempty"`
+
+	// ChartCapabilities are the kubernetes version and api versions the chart is rendered against
+	ChartCapabilities `json:",inline"`
+
+	// Discovery opts in to identifying images from sources other than the rendered chart
+	Discovery ChartDiscovery `json:"discovery,omitempty"`
+
+	// ChartValues are the values the chart is rendered with to identify its images
+	ChartValues `json:",inline"`
+
+	// ValueSets render the chart once per set, each merged over ChartValues, to identify the images of every
+	// configuration the chart is deployed with
+	ValueSets []ChartValues `json:"valueSets,omitempty"`
+}
+
+type ChartCapabilities struct {
+	// KubeVersion is the kubernetes version charts are rendered for, defaulting to helm's
+	KubeVersion string `json:"kubeVersion,omitempty"`
+
+	// APIVersions are api versions available in addition to helm's defaults, such as those of CRDs (e.g.
+	// monitoring.coreos.com/v1 or monitoring.coreos.com/v1/ServiceMonitor)
+	APIVersions []string `json:"apiVersions,omitempty"`
+}
+
+type ChartDiscovery struct {
+	// Annotations identifies the images declared by the artifacthub.io/images annotation of the chart and its dependencies
+	Annotations bool `json:"annotations,omitempty"`
+
+	// Values identifies the images declared in the chart's values as registry, repository and tag fields, including
+	// those of components the values don't enable
+	Values bool `json:"values,omitempty"`
+}
+
+type ChartValues struct {
+	// Values are inline values, taking precedence over ValuesFiles
+	Values map[string]interface{} `json:"values,omitempty"`
+
+	// ValuesFiles are paths or urls to values files, merged in order like helm's --values
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
 }
 
 type ChartImage struct {
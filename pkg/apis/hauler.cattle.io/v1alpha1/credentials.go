@@ -0,0 +1,38 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const CredentialsContentKind = "Credentials"
+
+type Credentials struct {
+	*metav1.TypeMeta  `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CredentialsSpec `json:"spec,omitempty"`
+}
+
+type CredentialsSpec struct {
+	Registries []RegistryCredential `json:"registries,omitempty"`
+}
+
+type RegistryCredential struct {
+	// Registry is the registry host or chart repository url the credentials are used for
+	Registry string `json:"registry"`
+
+	// Username is the username to authenticate as
+	Username string `json:"username,omitempty"`
+
+	// Password references the password or token to authenticate with, secrets are never declared inline
+	Password SecretRef `json:"password"`
+}
+
+// SecretRef references a secret held outside the content manifests
+type SecretRef struct {
+	// Env is the name of an environment variable holding the secret
+	Env string `json:"env,omitempty"`
+
+	// File is the path to a file holding the secret
+	File string `json:"file,omitempty"`
+}
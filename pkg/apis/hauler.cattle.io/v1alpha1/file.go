@@ -18,10 +18,18 @@ type FileSpec struct {
 }
 
 type File struct {
-	// Path is the path to the file contents, can be a local or remote path
+	// Path is the path to the file contents, can be a local or remote path.  Local directories and globs (e.g.
+	// rpms/*.rpm) are stored as a single archive of the tree, restored as a directory named after Name on extract.
 	Path string `json:"path"`
 
 	// Name is an optional field specifying the name of the file when specified,
 	// 	it will override any dynamic name discovery from Path
 	Name string `json:"name,omitempty"`
+
+	// SHA256 is the checksum the file contents must match, the sync fails when they don't
+	SHA256 string `json:"sha256,omitempty"`
+
+	// ChecksumURL is the local or remote path to a checksum file (e.g. SHA256SUMS) holding the checksum the file
+	// contents must match, the file is looked up by the last element of Path
+	ChecksumURL string `json:"checksumURL,omitempty"`
 }
@@ -0,0 +1,29 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const GitsContentKind = "Gits"
+
+type Gits struct {
+	*metav1.TypeMeta  `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GitSpec `json:"spec,omitempty"`
+}
+
+type GitSpec struct {
+	Repositories []Git `json:"repositories,omitempty"`
+}
+
+type Git struct {
+	// URL is the location of the repository, can be any url git understands (including file://) or a local path
+	URL string `json:"url"`
+
+	// Name is an optional name for the repository in the store, by default it's named after the last element of URL
+	Name string `json:"name,omitempty"`
+
+	// Refs are the branches and tags to store, by default every branch and tag is stored
+	Refs []string `json:"refs,omitempty"`
+}
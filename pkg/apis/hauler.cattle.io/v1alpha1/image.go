@@ -20,4 +20,34 @@ type ImageSpec struct {
 type Image struct {
 	// Name is the full location for the image, can be referenced by tags or digests
 	Name string `json:"name"`
+
+	// Platforms restricts a multi-platform image to the given platforms (os/arch[/variant]), when empty every platform
+	// of the image is kept.  Platforms can't be combined with Verify.
+	Platforms []string `json:"platforms,omitempty"`
+
+	// Verify requires the image to be signed with cosign before it's stored, its signatures are stored alongside it.
+	// Verified images are stored with every platform, which the signatures are for.
+	Verify *ImageVerification `json:"verify,omitempty"`
+}
+
+type ImageVerification struct {
+	// Key is the path to the public key the image must be signed with
+	Key string `json:"key,omitempty"`
+
+	// Keyless requires a keyless signature issued to an identity instead of a signature from a key
+	Keyless *KeylessVerification `json:"keyless,omitempty"`
+}
+
+type KeylessVerification struct {
+	// Identity is the email or uri the signing certificate must be issued to
+	Identity string `json:"identity"`
+
+	// Issuer is the OIDC issuer that must have authenticated the identity
+	Issuer string `json:"issuer"`
+
+	// Roots is the path to the trusted root (and intermediate) certificates that must have issued the signing certificate
+	Roots string `json:"roots"`
+
+	// RekorKey is the path to the public key of the transparency log the signature must have been logged in
+	RekorKey string `json:"rekorKey"`
 }
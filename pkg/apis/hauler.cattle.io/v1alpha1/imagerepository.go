@@ -0,0 +1,49 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const ImageRepositoriesContentKind = "ImageRepositories"
+
+type ImageRepositories struct {
+	*metav1.TypeMeta  `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ImageRepositoriesSpec `json:"spec,omitempty"`
+}
+
+type ImageRepositoriesSpec struct {
+	Repositories []ImageRepository `json:"repositories,omitempty"`
+}
+
+type ImageRepository struct {
+	// Name is the full location of the repository, without a tag or digest
+	Name string `json:"name"`
+
+	// Tags selects the tags of the repository to store, by default every tag is stored
+	Tags ImageTagFilter `json:"tags,omitempty"`
+
+	// Platforms restricts every selected image to the given platforms (os/arch[/variant]), it can't be combined with
+	// Verify
+	Platforms []string `json:"platforms,omitempty"`
+
+	// Verify requires every selected image to be signed with cosign before it's stored
+	Verify *ImageVerification `json:"verify,omitempty"`
+}
+
+type ImageTagFilter struct {
+	// Semver only selects tags that are semantic versions matching the constraint
+	Semver string `json:"semver,omitempty"`
+
+	// Regex only selects tags matching the regular expression
+	Regex string `json:"regex,omitempty"`
+
+	// Newest only selects the newest tags, when greater than zero.  Tags are ordered by semantic version, followed by
+	// the tags that aren't versions in reverse lexical order.
+	Newest int `json:"newest,omitempty"`
+
+	// Signatures selects the tags cosign stores signatures, attestations and sboms under (sha256-<digest>.sig, .att
+	// and .sbom), which are skipped otherwise
+	Signatures bool `json:"signatures,omitempty"`
+}
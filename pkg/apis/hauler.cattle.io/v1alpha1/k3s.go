@@ -13,5 +13,17 @@ type K3s struct {
 
 type K3sSpec struct {
 	Version string `json:"version"`
-	Arch    string `json:"arch"`
+	Arch    string `json:"arch,omitempty"`
+
+	// Arches are additional architectures to collect alongside Arch, amd64 is collected when neither is set
+	Arches []string `json:"arches,omitempty"`
+
+	// ReleaseURL is the base url of the releases, release artifacts are fetched from ReleaseURL/<version>/<artifact>
+	ReleaseURL string `json:"releaseURL,omitempty"`
+
+	// ChannelURL is the url of the release channels
+	ChannelURL string `json:"channelURL,omitempty"`
+
+	// InstallScriptURL is the url of the install script
+	InstallScriptURL string `json:"installScriptURL,omitempty"`
 }
@@ -0,0 +1,28 @@
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+const ManifestsCollectionKind = "Manifests"
+
+type Manifests struct {
+	*metav1.TypeMeta  `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ManifestsSpec `json:"spec,omitempty"`
+}
+
+type ManifestsSpec struct {
+	Manifests []Manifest `json:"manifests,omitempty"`
+}
+
+type Manifest struct {
+	// Path is a kustomization directory, a directory of yaml manifests or a single yaml manifest, relative to the content
+	// file declaring it
+	Path string `json:"path"`
+
+	// Name is the name the rendered manifests are stored under, defaults to the last element of Path with a .yaml extension
+	Name string `json:"name,omitempty"`
+
+	// ImagePaths are JSONPaths to images in the rendered manifests, in addition to the well known paths hauler recognizes
+	ImagePaths []string `json:"imagePaths,omitempty"`
+}
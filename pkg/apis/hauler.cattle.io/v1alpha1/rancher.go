@@ -0,0 +1,29 @@
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+const RancherCollectionKind = "Rancher"
+
+type Rancher struct {
+	*metav1.TypeMeta  `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RancherSpec `json:"spec,omitempty"`
+}
+
+type RancherSpec struct {
+	// Version is a rancher release (e.g. v2.6.3), selecting the release's image lists and the chart of the same version
+	Version string `json:"version"`
+
+	// ImageLists are the release's image lists to collect, defaults to rancher-images.txt and rancher-windows-images.txt
+	ImageLists []string `json:"imageLists,omitempty"`
+
+	// Sources filter the images of image lists that list their images' sources
+	Sources ImageTxtSources `json:"sources,omitempty"`
+
+	// ReleaseURL is the base url of the releases, image lists are fetched from ReleaseURL/<version>/<image list>
+	ReleaseURL string `json:"releaseURL,omitempty"`
+
+	// ChartRepoURL is the repository of the rancher chart
+	ChartRepoURL string `json:"chartRepoURL,omitempty"`
+}
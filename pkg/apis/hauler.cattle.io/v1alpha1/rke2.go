@@ -0,0 +1,29 @@
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+const RKE2CollectionKind = "RKE2"
+
+type RKE2 struct {
+	*metav1.TypeMeta  `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RKE2Spec `json:"spec,omitempty"`
+}
+
+type RKE2Spec struct {
+	// Version is an rke2 release (e.g. v1.22.5+rke2r1) or a channel (e.g. stable)
+	Version string `json:"version"`
+
+	// Arch is the architecture of the release artifacts, defaults to amd64
+	Arch string `json:"arch,omitempty"`
+
+	// ReleaseURL is the base url of the releases, release artifacts are fetched from ReleaseURL/<version>/<artifact>
+	ReleaseURL string `json:"releaseURL,omitempty"`
+
+	// ChannelURL is the url of the release channels
+	ChannelURL string `json:"channelURL,omitempty"`
+
+	// InstallScriptURL is the url of the install script
+	InstallScriptURL string `json:"installScriptURL,omitempty"`
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+)
+
+var _ authn.Keychain = (*Keychain)(nil)
+
+// Keychain resolves registry credentials, preferring credentials declared in content manifests and falling back to a
+// docker config and its credential helpers
+type Keychain struct {
+	dockerConfig string
+
+	mu          sync.RWMutex
+	credentials map[string]v1alpha1.RegistryCredential
+}
+
+// NewKeychain returns a Keychain falling back to the docker config at dockerConfig, which can be a directory or a
+// config.json.  An empty dockerConfig falls back to $DOCKER_CONFIG or ~/.docker, like the docker cli.
+func NewKeychain(dockerConfig string) *Keychain {
+	return &Keychain{
+		dockerConfig: dockerConfig,
+		credentials:  make(map[string]v1alpha1.RegistryCredential),
+	}
+}
+
+// Add declares credentials for registries, replacing any previously declared for the same registry
+func (k *Keychain) Add(creds ...v1alpha1.RegistryCredential) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, c := range creds {
+		host, err := registryHost(c.Registry)
+		if err != nil {
+			return err
+		}
+
+		if (c.Password.Env == "") == (c.Password.File == "") {
+			return fmt.Errorf("credentials for %s: password must reference exactly one of env or file", c.Registry)
+		}
+
+		k.credentials[host] = c
+	}
+	return nil
+}
+
+// Resolve implements authn.Keychain
+func (k *Keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	k.mu.RLock()
+	c, ok := k.credentials[target.RegistryStr()]
+	k.mu.RUnlock()
+
+	if ok {
+		password, err := readSecret(c.Password)
+		if err != nil {
+			return nil, fmt.Errorf("credentials for %s: %w", c.Registry, err)
+		}
+
+		return authn.FromConfig(authn.AuthConfig{
+			Username: c.Username,
+			Password: password,
+		}), nil
+	}
+
+	if k.dockerConfig == "" {
+		return authn.DefaultKeychain.Resolve(target)
+	}
+	return k.resolveDockerConfig(target)
+}
+
+// Basic returns the username and password for a registry host or chart repository url, both are empty when there are
+// no credentials for it
+func (k *Keychain) Basic(registry string) (string, string, error) {
+	host, err := registryHost(registry)
+	if err != nil {
+		return "", "", err
+	}
+
+	r, err := name.NewRegistry(host)
+	if err != nil {
+		return "", "", err
+	}
+
+	a, err := k.Resolve(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	cfg, err := a.Authorization()
+	if err != nil {
+		return "", "", err
+	}
+	return cfg.Username, cfg.Password, nil
+}
+
+// resolveDockerConfig resolves credentials the same way authn.DefaultKeychain does, but from the configured docker config
+func (k *Keychain) resolveDockerConfig(target authn.Resource) (authn.Authenticator, error) {
+	dir := k.dockerConfig
+	if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	cf, err := config.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	key := target.RegistryStr()
+	if key == name.DefaultRegistry {
+		key = authn.DefaultAuthKey
+	}
+
+	cfg, err := cf.GetAuthConfig(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Username == "" && cfg.Password == "" && cfg.Auth == "" && cfg.IdentityToken == "" && cfg.RegistryToken == "" {
+		return authn.Anonymous, nil
+	}
+
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		Auth:          cfg.Auth,
+		IdentityToken: cfg.IdentityToken,
+		RegistryToken: cfg.RegistryToken,
+	}), nil
+}
+
+// registryHost returns the normalized registry host of a registry host or url
+func registryHost(registry string) (string, error) {
+	host := registry
+	if strings.Contains(registry, "://") {
+		u, err := url.Parse(registry)
+		if err != nil {
+			return "", err
+		}
+		host = u.Host
+	} else if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+
+	r, err := name.NewRegistry(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry %q: %w", registry, err)
+	}
+	return r.RegistryStr(), nil
+}
+
+func readSecret(ref v1alpha1.SecretRef) (string, error) {
+	if ref.Env != "" {
+		v, ok := os.LookupEnv(ref.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", ref.Env)
+		}
+		return v, nil
+	}
+
+	data, err := os.ReadFile(ref.File)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
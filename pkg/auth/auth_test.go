@@ -0,0 +1,141 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/auth"
+)
+
+func TestKeychain(t *testing.T) {
+	tmpdir := t.TempDir()
+	t.Setenv("HAULER_TEST_PASSWORD", "from-env")
+
+	secret := filepath.Join(tmpdir, "password")
+	if err := os.WriteFile(secret, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// "docker:from-config" base64 encoded
+	config := `{"auths": {"registry.example.com": {"auth": "ZG9ja2VyOmZyb20tY29uZmln"}}}`
+	if err := os.WriteFile(filepath.Join(tmpdir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kc := auth.NewKeychain(tmpdir)
+	if err := kc.Add(
+		v1alpha1.RegistryCredential{
+			Registry: "private.example.com",
+			Username: "env",
+			Password: v1alpha1.SecretRef{Env: "HAULER_TEST_PASSWORD"},
+		},
+		v1alpha1.RegistryCredential{
+			Registry: "https://charts.example.com/stable",
+			Username: "file",
+			Password: v1alpha1.SecretRef{File: secret},
+		},
+		v1alpha1.RegistryCredential{
+			Registry: "docker.io",
+			Username: "hub",
+			Password: v1alpha1.SecretRef{Env: "HAULER_TEST_PASSWORD"},
+		},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+		want authn.AuthConfig
+	}{
+		{
+			name: "declared with env",
+			ref:  "private.example.com/library/busybox",
+			want: authn.AuthConfig{Username: "env", Password: "from-env"},
+		},
+		{
+			name: "declared with file for a chart repository url",
+			ref:  "charts.example.com/stable",
+			want: authn.AuthConfig{Username: "file", Password: "from-file"},
+		},
+		{
+			name: "declared for docker hub",
+			ref:  "busybox",
+			want: authn.AuthConfig{Username: "hub", Password: "from-env"},
+		},
+		{
+			name: "docker config",
+			ref:  "registry.example.com/library/busybox",
+			want: authn.AuthConfig{Username: "docker", Password: "from-config"},
+		},
+		{
+			name: "anonymous",
+			ref:  "public.example.com/library/busybox",
+			want: authn.AuthConfig{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := name.ParseReference(tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := kc.Resolve(r.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := a.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Username != tt.want.Username || got.Password != tt.want.Password {
+				t.Errorf("Resolve() = %s:%s, want %s:%s", got.Username, got.Password, tt.want.Username, tt.want.Password)
+			}
+		})
+	}
+}
+
+func TestKeychainAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		cred    v1alpha1.RegistryCredential
+		wantErr bool
+	}{
+		{
+			name:    "no password reference",
+			cred:    v1alpha1.RegistryCredential{Registry: "private.example.com"},
+			wantErr: true,
+		},
+		{
+			name: "both password references",
+			cred: v1alpha1.RegistryCredential{
+				Registry: "private.example.com",
+				Password: v1alpha1.SecretRef{Env: "PASSWORD", File: "password"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid",
+			cred: v1alpha1.RegistryCredential{
+				Registry: "private.example.com",
+				Password: v1alpha1.SecretRef{Env: "PASSWORD"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := auth.NewKeychain("").Add(tt.cred); (err != nil) != tt.wantErr {
+				t.Errorf("Add() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package chart
 
 import (
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/rancherfederal/ocil/pkg/artifacts"
-	"github.com/rancherfederal/ocil/pkg/artifacts/image"
 	"helm.sh/helm/v3/pkg/action"
 
 	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/content"
 	"github.com/rancherfederal/hauler/pkg/content/chart"
 	"github.com/rancherfederal/hauler/pkg/reference"
 )
@@ -14,33 +17,78 @@ var _ artifacts.OCICollection = (*tchart)(nil)
 
 // tchart is a thick chart that includes all the dependent images as well as the chart itself
 type tchart struct {
-	chart  *chart.Chart
-	config v1alpha1.ThickChart
+	chart      *chart.Chart
+	config     v1alpha1.ThickChart
+	remoteOpts []remote.Option
+
+	lock       sync.Mutex
+	computed   bool
+	contents   map[string]artifacts.OCI
+	detections []Detection
+	skipped    []SkippedImage
+}
+
+// Detector is implemented by collections that detect images, reporting where each image was found
+type Detector interface {
+	Detections() ([]Detection, error)
+}
+
+// SkippedImage is an image discovered in a chart's annotations or values that was skipped, since it couldn't be pulled
+type SkippedImage struct {
+	Image string
+	Err   error
+}
 
-	computed bool
-	contents map[string]artifacts.OCI
+// Skipper is implemented by collections that skip the images they can't pull rather than failing, reporting each one
+type Skipper interface {
+	Skipped() ([]SkippedImage, error)
 }
 
-func NewThickChart(cfg v1alpha1.ThickChart, opts *action.ChartPathOptions) (artifacts.OCICollection, error) {
-	o, err := chart.NewChart(cfg.Chart.Name, opts)
+// NewThickChart returns the collection of a chart and its images, the images (and charts stored in OCI registries) are
+// pulled with the given remote options
+func NewThickChart(cfg v1alpha1.ThickChart, opts *action.ChartPathOptions, ropts ...remote.Option) (artifacts.OCICollection, error) {
+	o, err := chart.NewChart(cfg.Chart.Name, opts, ropts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &tchart{
-		chart:    o,
-		config:   cfg,
-		contents: make(map[string]artifacts.OCI),
+		chart:      o,
+		config:     cfg,
+		contents:   make(map[string]artifacts.OCI),
+		remoteOpts: ropts,
 	}, nil
 }
 
 func (c *tchart) Contents() (map[string]artifacts.OCI, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	if err := c.compute(); err != nil {
 		return nil, err
 	}
 	return c.contents, nil
 }
 
+// Detections returns the images detected in the chart, tagged with the path that matched them
+func (c *tchart) Detections() ([]Detection, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.compute(); err != nil {
+		return nil, err
+	}
+	return c.detections, nil
+}
+
+// Skipped returns the images discovered in the chart that were skipped, along with the reason they couldn't be pulled
+func (c *tchart) Skipped() ([]SkippedImage, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.compute(); err != nil {
+		return nil, err
+	}
+	return c.skipped, nil
+}
+
 func (c *tchart) compute() error {
 	if c.computed {
 		return nil
@@ -80,24 +128,79 @@ func (c *tchart) dependentImages() error {
 		return err
 	}
 
-	imgs, err := ImagesInChart(ch)
+	vals, err := Values(c.config)
 	if err != nil {
 		return err
 	}
 
-	for _, img := range imgs.Spec.Images {
-		i, err := image.NewImage(img.Name)
+	caps, err := NewCapabilities(c.config.KubeVersion, c.config.APIVersions...)
+	if err != nil {
+		return err
+	}
+
+	detections, err := DetectImagesInChart(ch, caps, c.config.ImagePaths, vals...)
+	if err != nil {
+		return err
+	}
+
+	var discovered []Detection
+	if c.config.Discovery.Annotations {
+		found, err := ImagesInAnnotations(ch)
+		if err != nil {
+			return err
+		}
+		discovered = append(discovered, found...)
+	}
+
+	if c.config.Discovery.Values {
+		found, err := ImagesInValues(ch, vals...)
+		if err != nil {
+			return err
+		}
+		discovered = append(discovered, found...)
+	}
+
+	for _, img := range Images(detections).Spec.Images {
+		i, err := content.NewImage(img.Name, c.remoteOpts...)
 		if err != nil {
 			return err
 		}
 		c.contents[img.Name] = i
 	}
+
+	// Annotations and values are only a guess at a chart's images, an image discovered in them that can't be pulled is
+	// skipped rather than failing the chart
+	declared := make(map[string]bool)
+	for _, d := range detections {
+		declared[imageKey(d.Image)] = true
+	}
+	skipped := make(map[string]bool)
+	for _, img := range Images(discovered).Spec.Images {
+		if declared[imageKey(img.Name)] {
+			continue
+		}
+
+		i, err := content.NewImage(img.Name, c.remoteOpts...)
+		if err != nil {
+			c.skipped = append(c.skipped, SkippedImage{Image: img.Name, Err: err})
+			skipped[imageKey(img.Name)] = true
+			continue
+		}
+		c.contents[img.Name] = i
+	}
+
+	for _, d := range discovered {
+		if !skipped[imageKey(d.Image)] {
+			detections = append(detections, d)
+		}
+	}
+	c.detections = detections
 	return nil
 }
 
 func (c *tchart) extraImages() error {
 	for _, img := range c.config.ExtraImages {
-		i, err := image.NewImage(img.Reference)
+		i, err := content.NewImage(img.Reference, c.remoteOpts...)
 		if err != nil {
 			return err
 		}
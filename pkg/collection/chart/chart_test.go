@@ -0,0 +1,142 @@
+package chart_test
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"helm.sh/helm/v3/pkg/action"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/collection/chart"
+)
+
+func TestThickChartDiscovery(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	for _, image := range []string{"example/app:v1", "example/sidecar:v1"} {
+		ref, err := name.ParseReference(host + "/" + image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, empty.Image); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The rendered chart only uses the app image, its values also declare a sidecar and an image that doesn't exist
+	c := testChart()
+	c.Values["registry"] = host + "/example"
+	c.Values["sidecar"] = map[string]interface{}{"registry": host, "repository": "example/sidecar"}
+	c.Values["legacy"] = map[string]interface{}{"registry": host, "repository": "example/legacy"}
+
+	values, err := yaml.Marshal(c.Values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Raw = []*helmchart.File{{Name: chartutil.ValuesfileName, Data: values}}
+
+	dir := t.TempDir()
+	if err := chartutil.SaveDir(c, dir); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, c.Name())
+
+	tests := []struct {
+		name        string
+		cfg         v1alpha1.ThickChart
+		want        []string
+		wantSkipped []string
+		wantErr     bool
+	}{
+		{
+			name: "should skip discovered images that can't be pulled",
+			cfg: v1alpha1.ThickChart{
+				Chart:     v1alpha1.Chart{Name: path},
+				Discovery: v1alpha1.ChartDiscovery{Values: true},
+			},
+			want:        []string{"hauler/app:1.0.0", host + "/example/app:v1", host + "/example/sidecar:v1"},
+			wantSkipped: []string{host + "/example/legacy:v1"},
+		},
+		{
+			name: "should fail on rendered images that can't be pulled",
+			cfg: v1alpha1.ThickChart{
+				Chart:       v1alpha1.Chart{Name: path},
+				ChartValues: v1alpha1.ChartValues{Values: map[string]interface{}{"registry": host + "/missing"}},
+				Discovery:   v1alpha1.ChartDiscovery{Values: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should fail on extra images that can't be pulled",
+			cfg: v1alpha1.ThickChart{
+				Chart:       v1alpha1.Chart{Name: path},
+				ExtraImages: []v1alpha1.ChartImage{{Reference: host + "/example/legacy:v1"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := chart.NewThickChart(tt.cfg, &action.ChartPathOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contents, err := tc.Contents()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Contents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got []string
+			for ref := range contents {
+				got = append(got, ref)
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Contents() = %v, want %v", got, tt.want)
+			}
+
+			detections, err := tc.(chart.Detector).Detections()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range detections {
+				if strings.Contains(d.Image, "legacy") {
+					t.Errorf("Detections() reports skipped image %s", d.Image)
+				}
+			}
+
+			skipped, err := tc.(chart.Skipper).Skipped()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotSkipped []string
+			for _, s := range skipped {
+				if s.Err == nil {
+					t.Errorf("Skipped() reports %s without a reason", s.Image)
+				}
+				gotSkipped = append(gotSkipped, s.Image)
+			}
+			if !reflect.DeepEqual(gotSkipped, tt.wantSkipped) {
+				t.Errorf("Skipped() = %v, want %v", gotSkipped, tt.wantSkipped)
+			}
+		})
+	}
+}
@@ -3,9 +3,12 @@ package chart
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
+	"sort"
 	"strings"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	"helm.sh/helm/v3/pkg/action"
 	helmchart "helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chartutil"
@@ -18,63 +21,141 @@ import (
 	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
 )
 
-var defaultKnownImagePaths = []string{
-	// Deployments & DaemonSets
-	"{.spec.template.spec.initContainers[*].image}",
-	"{.spec.template.spec.containers[*].image}",
+// Detection is an image identified in a manifest, tagged with the path that matched it
+type Detection struct {
+	Image string
 
-	// Pods
-	"{.spec.initContainers[*].image}",
-	"{.spec.containers[*].image}",
+	// Path is the JSONPath (or other source) the image was found at
+	Path string
+
+	// Resource is the kind/name of the manifest the image was found in
+	Resource string
 }
 
-// ImagesInChart will render a chart and identify all dependent images from it
-func ImagesInChart(c *helmchart.Chart) (v1alpha1.Images, error) {
-	docs, err := template(c)
+// ImagesInChart will render a chart once for each set of values (or with no values when none are given) and identify
+// all dependent images from them
+func ImagesInChart(c *helmchart.Chart, values ...map[string]interface{}) (v1alpha1.Images, error) {
+	detections, err := DetectImagesInChart(c, nil, nil, values...)
 	if err != nil {
 		return v1alpha1.Images{}, err
 	}
 
-	var images []v1alpha1.Image
-	reader := yaml.NewYAMLReader(bufio.NewReader(strings.NewReader(docs)))
+	return Images(detections), nil
+}
+
+// DetectImagesInChart renders a chart against caps (or helm's default capabilities when nil) once for each set of
+// values (or with no values when none are given) and identifies the images in them at the known image paths and the
+// given paths
+func DetectImagesInChart(c *helmchart.Chart, caps *chartutil.Capabilities, paths []string, values ...map[string]interface{}) ([]Detection, error) {
+	if len(values) == 0 {
+		values = []map[string]interface{}{{}}
+	}
+
+	var detections []Detection
+	for _, vals := range values {
+		docs, err := template(c, caps, vals)
+		if err != nil {
+			return nil, err
+		}
+
+		found, err := DetectImages(strings.NewReader(docs), paths...)
+		if err != nil {
+			return nil, err
+		}
+		detections = append(detections, found...)
+	}
+	return detections, nil
+}
+
+// DetectImages identifies the images in a stream of yaml manifests at the known image paths and the given paths
+func DetectImages(manifests io.Reader, paths ...string) ([]Detection, error) {
+	known := append([]imagePath{}, defaultKnownImagePaths...)
+	for _, p := range paths {
+		known = append(known, imagePath{path: p})
+	}
+
+	var detections []Detection
+	seen := make(map[Detection]bool)
+	reader := yaml.NewYAMLReader(bufio.NewReader(manifests))
 	for {
 		raw, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return v1alpha1.Images{}, err
+			return nil, err
 		}
 
-		found := find(raw, defaultKnownImagePaths...)
-		for _, f := range found {
-			images = append(images, v1alpha1.Image{Name: f})
+		for _, d := range find(raw, known...) {
+			if seen[d] {
+				continue
+			}
+			seen[d] = true
+			detections = append(detections, d)
 		}
 	}
+	return detections, nil
+}
 
-	ims := v1alpha1.Images{
+// Images returns the unique images of detections, in the order they were first detected.  Images are compared by
+// their fully qualified references, so the same image detected in different forms is only returned once.
+func Images(detections []Detection) v1alpha1.Images {
+	var images []v1alpha1.Image
+	seen := make(map[string]bool)
+	for _, d := range detections {
+		key := imageKey(d.Image)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		images = append(images, v1alpha1.Image{Name: d.Image})
+	}
+
+	return v1alpha1.Images{
 		Spec: v1alpha1.ImageSpec{
 			Images: images,
 		},
 	}
-	return ims, nil
 }
 
-func template(c *helmchart.Chart) (string, error) {
+// imageKey normalizes an image reference, so the same image is recognized however it's written
+func imageKey(image string) string {
+	if r, err := name.ParseReference(image); err == nil {
+		return r.Name()
+	}
+	return image
+}
+
+// NewCapabilities returns helm's default capabilities for the given kubernetes version (or helm's default version when
+// empty) with additional api versions
+func NewCapabilities(kubeVersion string, apiVersions ...string) (*chartutil.Capabilities, error) {
+	caps := chartutil.DefaultCapabilities.Copy()
+	if kubeVersion != "" {
+		kv, err := chartutil.ParseKubeVersion(kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubernetes version %s: %w", kubeVersion, err)
+		}
+		caps.KubeVersion = *kv
+	}
+	caps.APIVersions = append(caps.APIVersions, apiVersions...)
+	return caps, nil
+}
+
+func template(c *helmchart.Chart, caps *chartutil.Capabilities, vals map[string]interface{}) (string, error) {
+	if caps == nil {
+		caps = chartutil.DefaultCapabilities
+	}
+
 	s := storage.Init(driver.NewMemory())
 
 	templateCfg := &action.Configuration{
 		RESTClientGetter: nil,
 		Releases:         s,
 		KubeClient:       &fake.PrintingKubeClient{Out: io.Discard},
-		Capabilities:     chartutil.DefaultCapabilities,
+		Capabilities:     caps,
 		Log:              func(format string, v ...interface{}) {},
 	}
 
-	// TODO: Do we need values if we're claiming this is best effort image detection?
-	//       Justification being: if users are relying on us to get images from their values, they could just add images to the []ImagesInChart spec of the Store api
-	vals := make(map[string]interface{})
-
 	client := action.NewInstall(templateCfg)
 	client.ReleaseName = "dry"
 	client.DryRun = true
@@ -82,6 +163,10 @@ func template(c *helmchart.Chart) (string, error) {
 	client.ClientOnly = true
 	client.IncludeCRDs = true
 
+	// Client only installs replace the configured capabilities with the defaults, overridden by these
+	client.KubeVersion = &caps.KubeVersion
+	client.APIVersions = caps.APIVersions
+
 	release, err := client.Run(c, vals)
 	if err != nil {
 		return "", err
@@ -90,27 +175,93 @@ func template(c *helmchart.Chart) (string, error) {
 	return release.Manifest, nil
 }
 
-func find(data []byte, paths ...string) []string {
+func find(data []byte, paths ...imagePath) []Detection {
 	var (
-		pathMatches []string
-		obj         interface{}
+		detections []Detection
+		obj        interface{}
 	)
 
 	if err := yaml.Unmarshal(data, &obj); err != nil {
 		return nil
 	}
+	manifest, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	kind, _ := manifest["kind"].(string)
+	var name string
+	if meta, ok := manifest["metadata"].(map[string]interface{}); ok {
+		name, _ = meta["name"].(string)
+	}
+	resource := kind + "/" + name
+
 	j := jsonpath.New("")
 	j.AllowMissingKeys(true)
 
 	for _, p := range paths {
-		r, err := parseJSONPath(obj, j, p)
+		if !p.appliesTo(kind) {
+			continue
+		}
+
+		r, err := parseJSONPath(obj, j, p.path)
 		if err != nil {
 			continue
 		}
 
-		pathMatches = append(pathMatches, r...)
+		for _, img := range r {
+			// Paths combining fields print incomplete images when some of the fields are missing
+			if strings.HasPrefix(img, ":") || strings.HasSuffix(img, ":") {
+				continue
+			}
+			detections = append(detections, Detection{Image: img, Path: p.path, Resource: resource})
+		}
+	}
+
+	for _, img := range findInEnv(manifest) {
+		detections = append(detections, Detection{Image: img, Path: envImagePath, Resource: resource})
+	}
+	return detections
+}
+
+// findInEnv returns the values of every environment variable named like an image, anywhere in a manifest
+func findInEnv(obj interface{}) []string {
+	var found []string
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		for k, v := range o {
+			if env, ok := v.([]interface{}); ok && k == "env" {
+				for _, e := range env {
+					ev, ok := e.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					n, _ := ev["name"].(string)
+					val, _ := ev["value"].(string)
+					if strings.Contains(strings.ToUpper(n), "IMAGE") && isImage(val) {
+						found = append(found, val)
+					}
+				}
+				continue
+			}
+			found = append(found, findInEnv(v)...)
+		}
+	case []interface{}:
+		for _, v := range o {
+			found = append(found, findInEnv(v)...)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// isImage returns whether s looks like an image reference rather than some other setting, requiring a tag or digest
+func isImage(s string) bool {
+	if !strings.Contains(s, ":") && !strings.Contains(s, "@") {
+		return false
 	}
-	return pathMatches
+	_, err := name.ParseReference(s)
+	return err == nil
 }
 
 func parseJSONPath(data interface{}, parser *jsonpath.JSONPath, template string) ([]string, error) {
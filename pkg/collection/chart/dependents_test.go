@@ -0,0 +1,334 @@
+package chart_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	helmchart "helm.sh/helm/v3/pkg/chart"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/collection/chart"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: {{ .Values.registry }}/app:{{ .Chart.AppVersion }}
+{{- if .Values.worker.enabled }}
+        - name: worker
+          image: {{ .Values.registry }}/worker:{{ .Chart.AppVersion }}
+{{- end }}
+`
+
+func testChart() *helmchart.Chart {
+	return &helmchart.Chart{
+		Metadata: &helmchart.Metadata{
+			APIVersion: "v2",
+			Name:       "app",
+			Version:    "1.0.0",
+			AppVersion: "v1",
+		},
+		Templates: []*helmchart.File{
+			{Name: "templates/deployment.yaml", Data: []byte(deployment)},
+		},
+		Values: map[string]interface{}{
+			"registry": "docker.io/example",
+			"worker":   map[string]interface{}{"enabled": false},
+		},
+	}
+}
+
+func TestImagesInChart(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []map[string]interface{}
+		want   []string
+	}{
+		{
+			name: "default values",
+			want: []string{"docker.io/example/app:v1"},
+		},
+		{
+			name: "values enabling a component",
+			values: []map[string]interface{}{
+				{"worker": map[string]interface{}{"enabled": true}},
+			},
+			want: []string{"docker.io/example/app:v1", "docker.io/example/worker:v1"},
+		},
+		{
+			name: "multiple value sets",
+			values: []map[string]interface{}{
+				{"registry": "mirror.example.com"},
+				{"worker": map[string]interface{}{"enabled": true}},
+			},
+			want: []string{"mirror.example.com/app:v1", "docker.io/example/app:v1", "docker.io/example/worker:v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imgs, err := chart.ImagesInChart(testChart(), tt.values...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, i := range imgs.Spec.Images {
+				got = append(got, i.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ImagesInChart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValues(t *testing.T) {
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(valuesFile, []byte("registry: file.example.com\nworker:\n  enabled: true\n  replicas: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     v1alpha1.ThickChart
+		want    []map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "no values",
+			want: []map[string]interface{}{{}},
+		},
+		{
+			name: "inline values over values files",
+			cfg: v1alpha1.ThickChart{
+				ChartValues: v1alpha1.ChartValues{
+					Values:      map[string]interface{}{"worker": map[string]interface{}{"enabled": false}},
+					ValuesFiles: []string{valuesFile},
+				},
+			},
+			want: []map[string]interface{}{
+				{"registry": "file.example.com", "worker": map[string]interface{}{"enabled": false, "replicas": float64(2)}},
+			},
+		},
+		{
+			name: "value sets over base values",
+			cfg: v1alpha1.ThickChart{
+				ChartValues: v1alpha1.ChartValues{
+					Values: map[string]interface{}{"registry": "base.example.com"},
+				},
+				ValueSets: []v1alpha1.ChartValues{
+					{},
+					{ValuesFiles: []string{valuesFile}},
+				},
+			},
+			want: []map[string]interface{}{
+				{"registry": "base.example.com"},
+				{"registry": "file.example.com", "worker": map[string]interface{}{"enabled": true, "replicas": float64(2)}},
+			},
+		},
+		{
+			name: "missing values file",
+			cfg: v1alpha1.ThickChart{
+				ValueSets: []v1alpha1.ChartValues{{ValuesFiles: []string{"does-not-exist.yaml"}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chart.Values(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Values() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Values() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const manifests = `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: backup
+              image: docker.io/example/backup:v1
+              env:
+                - name: RELATED_IMAGE_RESTORE
+                  value: docker.io/example/restore:v1
+                - name: IMAGE_PULL_POLICY
+                  value: Always
+---
+apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+metadata:
+  name: k8s
+spec:
+  image: quay.io/prometheus/prometheus:v2.32.1
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  image: docker.io/example/ignored:v1
+  sidecar:
+    image: docker.io/example/sidecar:v1
+---
+apiVersion: upgrade.cattle.io/v1
+kind: Plan
+metadata:
+  name: server
+spec:
+  version: v1.22.5-k3s1
+  upgrade:
+    image: rancher/k3s-upgrade
+---
+apiVersion: logging.banzaicloud.io/v1beta1
+kind: Logging
+metadata:
+  name: logging
+spec:
+  fluentd:
+    image:
+      repository: rancher/fluentd
+`
+
+func TestDetectImages(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  []chart.Detection
+	}{
+		{
+			name: "known paths",
+			want: []chart.Detection{
+				{Image: "docker.io/example/backup:v1", Path: "{.spec.jobTemplate.spec.template.spec.containers[*].image}", Resource: "CronJob/backup"},
+				{Image: "docker.io/example/restore:v1", Path: "env[name=*IMAGE*].value", Resource: "CronJob/backup"},
+				{Image: "quay.io/prometheus/prometheus:v2.32.1", Path: "{.spec.image}", Resource: "Prometheus/k8s"},
+				{Image: "rancher/k3s-upgrade:v1.22.5-k3s1", Path: "{.spec.upgrade.image}:{.spec.version}", Resource: "Plan/server"},
+			},
+		},
+		{
+			name:  "additional paths",
+			paths: []string{"{.spec.sidecar.image}"},
+			want: []chart.Detection{
+				{Image: "docker.io/example/backup:v1", Path: "{.spec.jobTemplate.spec.template.spec.containers[*].image}", Resource: "CronJob/backup"},
+				{Image: "docker.io/example/restore:v1", Path: "env[name=*IMAGE*].value", Resource: "CronJob/backup"},
+				{Image: "quay.io/prometheus/prometheus:v2.32.1", Path: "{.spec.image}", Resource: "Prometheus/k8s"},
+				{Image: "docker.io/example/sidecar:v1", Path: "{.spec.sidecar.image}", Resource: "Widget/widget"},
+				{Image: "rancher/k3s-upgrade:v1.22.5-k3s1", Path: "{.spec.upgrade.image}:{.spec.version}", Resource: "Plan/server"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chart.DetectImages(strings.NewReader(manifests), tt.paths...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectImages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const gatedDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: docker.io/example/app:{{ .Capabilities.KubeVersion.Version }}
+{{- if .Capabilities.APIVersions.Has "monitoring.coreos.com/v1" }}
+        - name: exporter
+          image: docker.io/example/exporter:v1
+{{- end }}
+`
+
+func TestDetectImagesInChartCapabilities(t *testing.T) {
+	tests := []struct {
+		name        string
+		kubeVersion string
+		apiVersions []string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "kubernetes version",
+			kubeVersion: "v1.25.3",
+			want:        []string{"docker.io/example/app:v1.25.3"},
+		},
+		{
+			name:        "additional api versions",
+			kubeVersion: "1.24.0",
+			apiVersions: []string{"monitoring.coreos.com/v1"},
+			want:        []string{"docker.io/example/app:v1.24.0", "docker.io/example/exporter:v1"},
+		},
+		{
+			name:        "kubernetes version unsupported by the chart",
+			kubeVersion: "1.20.0",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &helmchart.Chart{
+				Metadata: &helmchart.Metadata{
+					APIVersion:  "v2",
+					Name:        "app",
+					Version:     "1.0.0",
+					KubeVersion: ">=1.22.0-0",
+				},
+				Templates: []*helmchart.File{
+					{Name: "templates/deployment.yaml", Data: []byte(gatedDeployment)},
+				},
+			}
+
+			caps, err := chart.NewCapabilities(tt.kubeVersion, tt.apiVersions...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			detections, err := chart.DetectImagesInChart(c, caps, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectImagesInChart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, d := range detections {
+				got = append(got, d.Image)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectImagesInChart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := chart.NewCapabilities("not-a-version"); err == nil {
+		t.Errorf("NewCapabilities() with an invalid version succeeded")
+	}
+}
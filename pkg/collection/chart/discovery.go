@@ -0,0 +1,188 @@
+package chart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+// ImagesAnnotation is the Chart.yaml annotation artifacthub.io reads a chart's images from
+const ImagesAnnotation = "artifacthub.io/images"
+
+// ImagesInAnnotations identifies the images a chart and its dependencies declare in their artifacthub.io/images
+// annotation
+func ImagesInAnnotations(c *helmchart.Chart) ([]Detection, error) {
+	var detections []Detection
+	if c.Metadata != nil {
+		if data, ok := c.Metadata.Annotations[ImagesAnnotation]; ok {
+			var images []struct {
+				Name  string `json:"name"`
+				Image string `json:"image"`
+			}
+			if err := yaml.Unmarshal([]byte(data), &images); err != nil {
+				return nil, fmt.Errorf("parse %s annotation of chart %s: %w", ImagesAnnotation, c.Name(), err)
+			}
+
+			for _, i := range images {
+				if i.Image == "" {
+					continue
+				}
+				detections = append(detections, Detection{
+					Image:    i.Image,
+					Path:     "annotation:" + ImagesAnnotation,
+					Resource: "Chart/" + c.Name(),
+				})
+			}
+		}
+	}
+
+	for _, dep := range c.Dependencies() {
+		found, err := ImagesInAnnotations(dep)
+		if err != nil {
+			return nil, err
+		}
+		detections = append(detections, found...)
+	}
+	return detections, nil
+}
+
+// ImagesInValues identifies the images declared in a chart's values, merged with each set of values (or the chart's
+// own values when none are given), whether as registry, repository and tag (or digest) fields or as an image field
+// holding a complete reference.  Images without a tag use the appVersion of the chart whose values declare them, as
+// most charts default to it, so the images of a dependency use the dependency's own appVersion.
+func ImagesInValues(c *helmchart.Chart, values ...map[string]interface{}) ([]Detection, error) {
+	if len(values) == 0 {
+		values = []map[string]interface{}{{}}
+	}
+
+	var detections []Detection
+	for _, vals := range values {
+		merged, err := chartutil.CoalesceValues(c, vals)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range findInValues("", merged, chartAppVersion(c), subchartsByKey(c)) {
+			detections = append(detections, Detection{
+				Image:    f.image,
+				Path:     "values:" + f.path,
+				Resource: "Chart/" + c.Name(),
+			})
+		}
+	}
+	return detections, nil
+}
+
+type valuesImage struct {
+	image string
+	path  string
+}
+
+// findInValues finds the images in the values v at path, defaulting their tags to appVersion.  The values of the charts
+// in subcharts are found under their keys, with their own appVersion.
+func findInValues(path string, v interface{}, appVersion string, subcharts map[string]*helmchart.Chart) []valuesImage {
+	var obj map[string]interface{}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		obj = t
+	case chartutil.Values:
+		obj = t
+	default:
+		return nil
+	}
+
+	var found []valuesImage
+	if img := imageFromFields(obj, appVersion); img != "" {
+		found = append(found, valuesImage{image: img, path: path})
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+
+		if s, ok := obj[k].(string); ok && strings.HasSuffix(strings.ToLower(k), "image") && isImage(s) {
+			found = append(found, valuesImage{image: s, path: p})
+			continue
+		}
+		if sc, ok := subcharts[k]; ok {
+			found = append(found, findInValues(p, obj[k], chartAppVersion(sc), subchartsByKey(sc))...)
+			continue
+		}
+		found = append(found, findInValues(p, obj[k], appVersion, nil)...)
+	}
+	return found
+}
+
+func chartAppVersion(c *helmchart.Chart) string {
+	if c.Metadata == nil {
+		return ""
+	}
+	return c.Metadata.AppVersion
+}
+
+// subchartsByKey returns the dependencies of a chart by the keys their values are found under, their names or aliases
+func subchartsByKey(c *helmchart.Chart) map[string]*helmchart.Chart {
+	deps := make(map[string]*helmchart.Chart)
+	for _, dep := range c.Dependencies() {
+		deps[dep.Name()] = dep
+	}
+	if c.Metadata != nil {
+		for _, d := range c.Metadata.Dependencies {
+			if sc, ok := deps[d.Name]; ok && d.Alias != "" {
+				deps[d.Alias] = sc
+			}
+		}
+	}
+	return deps
+}
+
+// imageFromFields combines the registry, repository and tag or digest fields of a values object into an image
+func imageFromFields(obj map[string]interface{}, appVersion string) string {
+	repository, _ := obj["repository"].(string)
+	if repository == "" {
+		return ""
+	}
+
+	img := repository
+	if registry, _ := obj["registry"].(string); registry != "" {
+		img = strings.TrimSuffix(registry, "/") + "/" + repository
+	}
+
+	if digest, _ := obj["digest"].(string); digest != "" {
+		img += "@" + digest
+	} else if tag := scalar(obj["tag"]); tag != "" {
+		img += ":" + tag
+	} else if appVersion != "" {
+		img += ":" + appVersion
+	} else {
+		return ""
+	}
+
+	if !isImage(img) {
+		return ""
+	}
+	return img
+}
+
+// scalar returns a string or number field as a string, since unquoted tags like 1.2 are decoded as numbers
+func scalar(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64, int, int64:
+		return fmt.Sprint(t)
+	}
+	return ""
+}
@@ -0,0 +1,139 @@
+package chart_test
+
+import (
+	"reflect"
+	"testing"
+
+	helmchart "helm.sh/helm/v3/pkg/chart"
+
+	"github.com/rancherfederal/hauler/pkg/collection/chart"
+)
+
+func discoveryChart() *helmchart.Chart {
+	c := &helmchart.Chart{
+		Metadata: &helmchart.Metadata{
+			APIVersion: "v2",
+			Name:       "app",
+			Version:    "1.0.0",
+			AppVersion: "v1",
+			Annotations: map[string]string{
+				chart.ImagesAnnotation: "- name: app\n  image: docker.io/example/app:v1\n- name: migrations\n  image: docker.io/example/migrations:v1\n",
+			},
+		},
+		Values: map[string]interface{}{
+			"image": map[string]interface{}{
+				"registry":   "docker.io",
+				"repository": "example/app",
+				"tag":        "",
+			},
+			"worker": map[string]interface{}{
+				"enabled": false,
+				"image": map[string]interface{}{
+					"repository": "example/worker",
+					"tag":        1.2,
+				},
+			},
+			"initImage": "busybox:1.34",
+			"name":      "not-an-image",
+		},
+	}
+
+	c.AddDependency(&helmchart.Chart{
+		Metadata: &helmchart.Metadata{
+			APIVersion: "v2",
+			Name:       "cache",
+			Version:    "2.0.0",
+			AppVersion: "6.2",
+			Annotations: map[string]string{
+				chart.ImagesAnnotation: "- name: redis\n  image: docker.io/library/redis:6.2\n",
+			},
+		},
+		Values: map[string]interface{}{
+			"image": map[string]interface{}{
+				"repository": "library/redis",
+				"digest":     "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			},
+			"exporter": map[string]interface{}{
+				"repository": "example/redis-exporter",
+			},
+		},
+	})
+	return c
+}
+
+func TestImagesInAnnotations(t *testing.T) {
+	got, err := chart.ImagesInAnnotations(discoveryChart())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []chart.Detection{
+		{Image: "docker.io/example/app:v1", Path: "annotation:artifacthub.io/images", Resource: "Chart/app"},
+		{Image: "docker.io/example/migrations:v1", Path: "annotation:artifacthub.io/images", Resource: "Chart/app"},
+		{Image: "docker.io/library/redis:6.2", Path: "annotation:artifacthub.io/images", Resource: "Chart/cache"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ImagesInAnnotations() = %v, want %v", got, want)
+	}
+}
+
+func TestImagesInValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []map[string]interface{}
+		want   []chart.Detection
+	}{
+		{
+			name: "chart values",
+			want: []chart.Detection{
+				{Image: "example/redis-exporter:6.2", Path: "values:cache.exporter", Resource: "Chart/app"},
+				{Image: "library/redis@sha256:0000000000000000000000000000000000000000000000000000000000000000", Path: "values:cache.image", Resource: "Chart/app"},
+				{Image: "docker.io/example/app:v1", Path: "values:image", Resource: "Chart/app"},
+				{Image: "busybox:1.34", Path: "values:initImage", Resource: "Chart/app"},
+				{Image: "example/worker:1.2", Path: "values:worker.image", Resource: "Chart/app"},
+			},
+		},
+		{
+			name: "values overriding the tag",
+			values: []map[string]interface{}{
+				{"image": map[string]interface{}{"tag": "v2"}, "initImage": nil},
+			},
+			want: []chart.Detection{
+				{Image: "example/redis-exporter:6.2", Path: "values:cache.exporter", Resource: "Chart/app"},
+				{Image: "library/redis@sha256:0000000000000000000000000000000000000000000000000000000000000000", Path: "values:cache.image", Resource: "Chart/app"},
+				{Image: "docker.io/example/app:v2", Path: "values:image", Resource: "Chart/app"},
+				{Image: "example/worker:1.2", Path: "values:worker.image", Resource: "Chart/app"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chart.ImagesInValues(discoveryChart(), tt.values...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ImagesInValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	got := chart.Images([]chart.Detection{
+		{Image: "nginx:1.21"},
+		{Image: "docker.io/library/nginx:1.21"},
+		{Image: "index.docker.io/library/nginx:1.21"},
+		{Image: "quay.io/example/app:v1"},
+	})
+
+	var names []string
+	for _, i := range got.Spec.Images {
+		names = append(names, i.Name)
+	}
+	want := []string{"nginx:1.21", "quay.io/example/app:v1"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Images() = %v, want %v", names, want)
+	}
+}
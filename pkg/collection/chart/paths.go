@@ -0,0 +1,63 @@
+package chart
+
+// imagePath is a JSONPath template printing the images of a manifest, only applied to manifests of the given kinds
+// when any are given.  Templates may combine fields, such as a repository and tag, into a single image.
+type imagePath struct {
+	path  string
+	kinds []string
+}
+
+// defaultKnownImagePaths is the library of well known paths to images in workloads and common CRDs
+var defaultKnownImagePaths = []imagePath{
+	// Deployments, DaemonSets, StatefulSets, ReplicaSets & Jobs
+	{path: "{.spec.template.spec.initContainers[*].image}"},
+	{path: "{.spec.template.spec.containers[*].image}"},
+	{path: "{.spec.template.spec.ephemeralContainers[*].image}"},
+
+	// CronJobs
+	{path: "{.spec.jobTemplate.spec.template.spec.initContainers[*].image}"},
+	{path: "{.spec.jobTemplate.spec.template.spec.containers[*].image}"},
+
+	// Pods
+	{path: "{.spec.initContainers[*].image}"},
+	{path: "{.spec.containers[*].image}"},
+	{path: "{.spec.ephemeralContainers[*].image}"},
+
+	// Prometheus operator
+	{path: "{.spec.image}", kinds: []string{"Prometheus", "Alertmanager", "ThanosRuler"}},
+	{path: "{.spec.thanos.image}", kinds: []string{"Prometheus"}},
+
+	// Elastic operator
+	{path: "{.spec.image}", kinds: []string{"Elasticsearch", "Kibana", "ApmServer", "EnterpriseSearch", "Beat", "Agent", "Logstash", "ElasticMapsServer"}},
+	{path: "{.spec.nodeSets[*].podTemplate.spec.initContainers[*].image}", kinds: []string{"Elasticsearch"}},
+	{path: "{.spec.nodeSets[*].podTemplate.spec.containers[*].image}", kinds: []string{"Elasticsearch"}},
+	{path: "{.spec.podTemplate.spec.containers[*].image}", kinds: []string{"Kibana", "ApmServer", "EnterpriseSearch", "Logstash", "ElasticMapsServer"}},
+	{path: "{.spec.daemonSet.podTemplate.spec.containers[*].image}", kinds: []string{"Beat", "Agent"}},
+	{path: "{.spec.deployment.podTemplate.spec.containers[*].image}", kinds: []string{"Beat", "Agent"}},
+
+	// Rancher system-upgrade-controller
+	{path: "{.spec.upgrade.image}:{.spec.version}", kinds: []string{"Plan"}},
+	{path: "{.spec.prepare.image}", kinds: []string{"Plan"}},
+
+	// Rancher logging operator
+	{path: "{.spec.fluentd.image.repository}:{.spec.fluentd.image.tag}", kinds: []string{"Logging"}},
+	{path: "{.spec.fluentd.configReloaderImage.repository}:{.spec.fluentd.configReloaderImage.tag}", kinds: []string{"Logging"}},
+	{path: "{.spec.fluentbit.image.repository}:{.spec.fluentbit.image.tag}", kinds: []string{"Logging"}},
+}
+
+// envImagePath tags the images found in container environment variables named like images, such as the
+// RELATED_IMAGE_* variables operators use
+const envImagePath = "env[name=*IMAGE*].value"
+
+// appliesTo returns whether the path applies to a manifest of the given kind
+func (p imagePath) appliesTo(kind string) bool {
+	if len(p.kinds) == 0 {
+		return true
+	}
+	for _, k := range p.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
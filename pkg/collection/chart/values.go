@@ -0,0 +1,60 @@
+package chart
+
+import (
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+)
+
+// Values returns every set of values a thick chart is rendered with: one per value set merged over the chart's own
+// values, or just the chart's own values when it has no value sets
+func Values(cfg v1alpha1.ThickChart) ([]map[string]interface{}, error) {
+	base, err := loadValues(cfg.ChartValues)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.ValueSets) == 0 {
+		return []map[string]interface{}{base}, nil
+	}
+
+	sets := make([]map[string]interface{}, len(cfg.ValueSets))
+	for i, vs := range cfg.ValueSets {
+		vals, err := loadValues(vs)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = mergeValues(base, vals)
+	}
+	return sets, nil
+}
+
+func loadValues(cv v1alpha1.ChartValues) (map[string]interface{}, error) {
+	opts := &values.Options{ValueFiles: cv.ValuesFiles}
+	vals, err := opts.MergeValues(getter.All(cli.New()))
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeValues(vals, cv.Values), nil
+}
+
+// mergeValues deeply merges b over a without modifying either, the same way helm merges values files
+func mergeValues(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		if v, ok := v.(map[string]interface{}); ok {
+			if av, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeValues(av, v)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
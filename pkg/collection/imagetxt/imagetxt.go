@@ -12,18 +12,22 @@ import (
 	"github.com/rancherfederal/hauler/pkg/log"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	artifact "github.com/rancherfederal/ocil/pkg/artifacts"
 	"github.com/rancherfederal/ocil/pkg/artifacts/file/getter"
-	"github.com/rancherfederal/ocil/pkg/artifacts/image"
+
+	"github.com/rancherfederal/hauler/pkg/content"
 )
 
 type ImageTxt struct {
 	Ref            string
 	IncludeSources map[string]bool
 	ExcludeSources map[string]bool
+	RemoteOptions  []remote.Option
 
 	lock     *sync.Mutex
 	client   *getter.Client
+	content  io.Reader
 	computed bool
 	contents map[string]artifact.OCI
 }
@@ -66,6 +70,32 @@ func WithExcludeSources(exclude ...string) Option {
 	return withExcludeSources(exclude)
 }
 
+type withRemoteOptions []remote.Option
+
+func (o withRemoteOptions) Apply(it *ImageTxt) error {
+	it.RemoteOptions = append(it.RemoteOptions, o...)
+	return nil
+}
+
+// WithRemoteOptions sets the options images are pulled with
+func WithRemoteOptions(opts ...remote.Option) Option {
+	return withRemoteOptions(opts)
+}
+
+type withContent struct {
+	io.Reader
+}
+
+func (o withContent) Apply(it *ImageTxt) error {
+	it.content = o.Reader
+	return nil
+}
+
+// WithContent reads the image list from r, which the caller fetched already, rather than fetching it from Ref
+func WithContent(r io.Reader) Option {
+	return withContent{r}
+}
+
 func New(ref string, opts ...Option) (*ImageTxt, error) {
 	it := &ImageTxt{
 		Ref: ref,
@@ -103,13 +133,17 @@ func (it *ImageTxt) compute() error {
 
 	ctx := context.TODO()
 
-	rc, err := it.client.ContentFrom(ctx, it.Ref)
-	if err != nil {
-		return fmt.Errorf("fetch image.txt ref %s: %w", it.Ref, err)
+	r := it.content
+	if r == nil {
+		rc, err := it.client.ContentFrom(ctx, it.Ref)
+		if err != nil {
+			return fmt.Errorf("fetch image.txt ref %s: %w", it.Ref, err)
+		}
+		defer rc.Close()
+		r = rc
 	}
-	defer rc.Close()
 
-	entries, err := splitImagesTxt(rc)
+	entries, err := splitImagesTxt(r)
 	if err != nil {
 		return fmt.Errorf("parse image.txt ref %s: %v", it.Ref, err)
 	}
@@ -174,7 +208,7 @@ func (it *ImageTxt) compute() error {
 		}
 
 		if pullAll || matchesSourceFilter {
-			curImage, err := image.NewImage(e.Reference.String())
+			curImage, err := content.NewImage(e.Reference.String(), it.RemoteOptions...)
 			if err != nil {
 				return fmt.Errorf("pull image %s: %v", e.Reference, err)
 			}
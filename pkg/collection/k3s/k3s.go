@@ -2,57 +2,79 @@ package k3s
 
 import (
 	"bufio"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
-	"net/url"
-	"path"
 	"strings"
+	"sync"
 
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/rancherfederal/ocil/pkg/artifacts"
-	"github.com/rancherfederal/ocil/pkg/artifacts/image"
 
 	"github.com/rancherfederal/ocil/pkg/artifacts/file"
 
 	"github.com/rancherfederal/ocil/pkg/artifacts/file/getter"
 
+	"github.com/rancherfederal/hauler/internal/release"
+	"github.com/rancherfederal/hauler/pkg/content"
 	"github.com/rancherfederal/hauler/pkg/reference"
 )
 
 var _ artifacts.OCICollection = (*k3s)(nil)
 
 const (
-	releaseUrl   = "https://github.com/k3s-io/k3s/releases/download"
-	channelUrl   = "https://update.k3s.io/v1-release/channels"
-	bootstrapUrl = "https://get.k3s.io"
+	DefaultReleaseURL       = "https://github.com/k3s-io/k3s/releases/download"
+	DefaultChannelURL       = "https://update.k3s.io/v1-release/channels"
+	DefaultInstallScriptURL = "https://get.k3s.io"
+
+	DefaultArch = "amd64"
 )
 
 var (
 	ErrImagesNotFound     = errors.New("k3s dependent images not found")
 	ErrFetchingImages     = errors.New("failed to fetch k3s dependent images")
 	ErrExecutableNotfound = errors.New("k3s executable not found")
-	ErrChannelNotFound    = errors.New("desired k3s channel not found")
+	ErrAirgapNotFound     = errors.New("k3s airgap images not found")
+	ErrChannelNotFound    = release.ErrChannelNotFound
 )
 
-type k3s struct {
-	version string
-	arch    string
+// DefaultEndpoints are the upstream k3s release, channel and install script locations
+var DefaultEndpoints = release.Endpoints{
+	Release:       DefaultReleaseURL,
+	Channel:       DefaultChannelURL,
+	InstallScript: DefaultInstallScriptURL,
+}
 
-	computed bool
-	contents map[string]artifacts.OCI
-	channels map[string]string
-	client   *getter.Client
+type k3s struct {
+	version   string
+	arches    []string
+	endpoints release.Endpoints
+
+	lock       sync.Mutex
+	computed   bool
+	contents   map[string]artifacts.OCI
+	remoteOpts []remote.Option
 }
 
-func NewK3s(version string) (artifacts.OCICollection, error) {
+// NewK3s returns the collection of a k3s release for each of arches (amd64 when none are given): the executable and
+// airgap images tarball of each arch, the image list shared by every arch and the install script, along with every image
+// of the image list, which are pulled with the given remote options.  The version is expected to be resolved from a
+// channel already, and endpoints that are empty default to upstream.
+func NewK3s(version string, arches []string, endpoints release.Endpoints, opts ...remote.Option) (artifacts.OCICollection, error) {
+	if len(arches) == 0 {
+		arches = []string{DefaultArch}
+	}
 	return &k3s{
-		version:  version,
-		contents: make(map[string]artifacts.OCI),
+		version:    version,
+		arches:     arches,
+		endpoints:  endpoints.WithDefaults(DefaultEndpoints),
+		contents:   make(map[string]artifacts.OCI),
+		remoteOpts: opts,
 	}, nil
 }
 
 func (k *k3s) Contents() (map[string]artifacts.OCI, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
 	if err := k.compute(); err != nil {
 		return nil, err
 	}
@@ -64,18 +86,17 @@ func (k *k3s) compute() error {
 		return nil
 	}
 
-	if err := k.fetchChannels(); err == nil {
-		if version, ok := k.channels[k.version]; ok {
-			k.version = version
-		}
-	}
-
 	if err := k.images(); err != nil {
 		return err
 	}
 
-	if err := k.executable(); err != nil {
-		return err
+	for _, arch := range k.arches {
+		if err := k.executable(arch); err != nil {
+			return err
+		}
+		if err := k.airgapImages(arch); err != nil {
+			return err
+		}
 	}
 
 	if err := k.bootstrap(); err != nil {
@@ -86,93 +107,83 @@ func (k *k3s) compute() error {
 	return nil
 }
 
-func (k *k3s) executable() error {
+func (k *k3s) executable(arch string) error {
 	n := "k3s"
-	if k.arch != "" && k.arch != "amd64" {
-		n = fmt.Sprintf("name-%s", k.arch)
+	switch arch {
+	case "amd64":
+	case "arm", "armhf":
+		n = "k3s-armhf"
+	default:
+		n = fmt.Sprintf("k3s-%s", arch)
 	}
-	fref := k.releaseUrl(n)
 
-	resp, err := http.Head(fref)
-	if resp.StatusCode != http.StatusOK || err != nil {
-		return ErrExecutableNotfound
+	if err := k.releaseFile(n); err != nil {
+		return fmt.Errorf("%w: %v", ErrExecutableNotfound, err)
 	}
+	return nil
+}
 
-	f := file.NewFile(fref)
+func (k *k3s) airgapImages(arch string) error {
+	if arch == "armhf" {
+		arch = "arm"
+	}
 
-	ref := fmt.Sprintf("%s/k3s:%s", reference.DefaultNamespace, k.dnsCompliantVersion())
-	k.contents[ref] = f
+	if err := k.releaseFile(fmt.Sprintf("k3s-airgap-images-%s.tar.gz", arch)); err != nil {
+		return fmt.Errorf("%w: %v", ErrAirgapNotFound, err)
+	}
+	return nil
+}
+
+// releaseFile adds an artifact of the release, tagged with the release's version
+func (k *k3s) releaseFile(artifact string) error {
+	fref := k.endpoints.ReleaseURL(k.version, artifact)
+	if err := release.Exists(k.endpoints.Client, fref); err != nil {
+		return err
+	}
+
+	ref := fmt.Sprintf("%s/%s:%s", reference.DefaultNamespace, artifact, k.dnsCompliantVersion())
+	k.contents[ref] = file.NewFile(fref)
 	return nil
 }
 
 func (k *k3s) bootstrap() error {
 	c := getter.NewClient(getter.ClientOptions{NameOverride: "k3s-init.sh"})
-	f := file.NewFile(bootstrapUrl, file.WithClient(c))
+	f := file.NewFile(k.endpoints.InstallScript, file.WithClient(c))
 
 	ref := fmt.Sprintf("%s/k3s-init.sh:%s", reference.DefaultNamespace, reference.DefaultTag)
 	k.contents[ref] = f
 	return nil
 }
 
+// images adds the image list and every image in it, k3s publishes a single list of multi-arch images for every arch
 func (k *k3s) images() error {
-	resp, err := http.Get(k.releaseUrl("k3s-images.txt"))
-	if resp.StatusCode != http.StatusOK {
-		return ErrFetchingImages
-	} else if err != nil {
-		return ErrImagesNotFound
+	rc, err := release.Get(k.endpoints.Client, k.endpoints.ReleaseURL(k.version, "k3s-images.txt"))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFetchingImages, err)
 	}
-	defer resp.Body.Close()
+	defer rc.Close()
 
-	scanner := bufio.NewScanner(resp.Body)
+	scanner := bufio.NewScanner(rc)
 	for scanner.Scan() {
-		reference := scanner.Text()
-		o, err := image.NewImage(reference)
+		reference := strings.TrimSpace(scanner.Text())
+		if reference == "" {
+			continue
+		}
+
+		o, err := content.NewImage(reference, k.remoteOpts...)
 		if err != nil {
 			return err
 		}
 
 		k.contents[reference] = o
 	}
-	return nil
-}
-
-func (k *k3s) releaseUrl(artifact string) string {
-	u, _ := url.Parse(releaseUrl)
-	complete := []string{u.Path}
-	u.Path = path.Join(append(complete, []string{k.version, artifact}...)...)
-	return u.String()
-}
-
-func (k *k3s) dnsCompliantVersion() string {
-	return strings.ReplaceAll(k.version, "+", "-")
-}
-
-func (k *k3s) fetchChannels() error {
-	resp, err := http.Get(channelUrl)
-	if err != nil {
-		return err
-	}
-
-	var c channel
-	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+	if err := scanner.Err(); err != nil {
 		return err
 	}
 
-	channels := make(map[string]string)
-	for _, ch := range c.Data {
-		channels[ch.Name] = ch.Latest
-	}
-
-	k.channels = channels
-	return nil
-}
-
-type channel struct {
-	Data []channelData `json:"data"`
+	return k.releaseFile("k3s-images.txt")
 }
 
-type channelData struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Latest string `json:"latest"`
+func (k *k3s) dnsCompliantVersion() string {
+	return strings.ReplaceAll(k.version, "+", "-")
 }
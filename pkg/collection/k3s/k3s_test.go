@@ -0,0 +1,112 @@
+package k3s_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/rancherfederal/hauler/internal/release/releasetest"
+	"github.com/rancherfederal/hauler/pkg/collection/k3s"
+)
+
+const version = "v1.22.5+k3s1"
+
+// images are listed by the k3s-images.txt of every release
+var images = []string{"rancher/klipper-helm:v0.6.6-build20211022", "rancher/pause:3.1"}
+
+// artifacts are the executables and airgap images tarballs of a release for amd64 and arm64
+var artifacts = []string{"k3s", "k3s-arm64", "k3s-airgap-images-amd64.tar.gz", "k3s-airgap-images-arm64.tar.gz"}
+
+func TestK3sContents(t *testing.T) {
+	srv, refs := releasetest.NewRelease(t, version, "k3s-images.txt", images, artifacts...)
+
+	tests := []struct {
+		name   string
+		arches []string
+		want   []string
+	}{
+		{
+			name: "should collect amd64 by default",
+			want: []string{
+				"hauler/k3s-airgap-images-amd64.tar.gz:v1.22.5-k3s1",
+				"hauler/k3s:v1.22.5-k3s1",
+			},
+		},
+		{
+			name:   "should collect every arch",
+			arches: []string{"amd64", "arm64"},
+			want: []string{
+				"hauler/k3s-airgap-images-amd64.tar.gz:v1.22.5-k3s1",
+				"hauler/k3s-airgap-images-arm64.tar.gz:v1.22.5-k3s1",
+				"hauler/k3s-arm64:v1.22.5-k3s1",
+				"hauler/k3s:v1.22.5-k3s1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := k3s.NewK3s(version, tt.arches, srv.Endpoints())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contents, err := k.Contents()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for ref := range contents {
+				got = append(got, ref)
+			}
+			sort.Strings(got)
+
+			want := append(append([]string{
+				"hauler/k3s-images.txt:v1.22.5-k3s1",
+				"hauler/k3s-init.sh:latest",
+			}, tt.want...), refs...)
+			sort.Strings(want)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Contents() = %v, want %v", got, want)
+			}
+
+			m, err := contents["hauler/k3s-init.sh:latest"].Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if title := m.Layers[0].Annotations[ocispec.AnnotationTitle]; title != "k3s-init.sh" {
+				t.Errorf("install script title = %s, want k3s-init.sh", title)
+			}
+		})
+	}
+}
+
+func TestK3sMissingArch(t *testing.T) {
+	srv, _ := releasetest.NewRelease(t, version, "k3s-images.txt", images, artifacts...)
+
+	k, err := k3s.NewK3s(version, []string{"s390x"}, srv.Endpoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := k.Contents(); !errors.Is(err, k3s.ErrExecutableNotfound) {
+		t.Errorf("Contents() error = %v, want %v", err, k3s.ErrExecutableNotfound)
+	}
+}
+
+func TestK3sMissingImages(t *testing.T) {
+	srv, _ := releasetest.NewRelease(t, version, "k3s-images.txt", images, artifacts...)
+
+	k, err := k3s.NewK3s("v1.23.1+k3s1", nil, srv.Endpoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := k.Contents(); !errors.Is(err, k3s.ErrFetchingImages) {
+		t.Errorf("Contents() error = %v, want %v", err, k3s.ErrFetchingImages)
+	}
+}
@@ -0,0 +1,184 @@
+package manifests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	tchart "github.com/rancherfederal/hauler/pkg/collection/chart"
+	"github.com/rancherfederal/hauler/pkg/content"
+	"github.com/rancherfederal/hauler/pkg/reference"
+)
+
+var _ artifacts.OCICollection = (*manifests)(nil)
+
+var _ tchart.Detector = (*manifests)(nil)
+
+// manifests is a kustomization or plain yaml manifests, rendered and stored as a single file along with the images they
+// reference
+type manifests struct {
+	config     v1alpha1.Manifest
+	remoteOpts []remote.Option
+
+	lock       sync.Mutex
+	computed   bool
+	contents   map[string]artifacts.OCI
+	detections []tchart.Detection
+}
+
+// NewManifests returns the collection of the manifests at cfg's path, rendered with kustomize when the path is a
+// kustomization, and the images found in them, which are pulled with the given remote options
+func NewManifests(cfg v1alpha1.Manifest, ropts ...remote.Option) (artifacts.OCICollection, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("manifests: path is required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = Name(cfg.Path)
+	}
+
+	return &manifests{
+		config:     cfg,
+		contents:   make(map[string]artifacts.OCI),
+		remoteOpts: ropts,
+	}, nil
+}
+
+// Name returns the name manifests at path are stored under by default, the last element of the path with a .yaml
+// extension
+func Name(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".yaml"
+}
+
+func (m *manifests) Contents() (map[string]artifacts.OCI, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err := m.compute(); err != nil {
+		return nil, err
+	}
+	return m.contents, nil
+}
+
+// Detections returns the images detected in the manifests, tagged with the path that matched them
+func (m *manifests) Detections() ([]tchart.Detection, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err := m.compute(); err != nil {
+		return nil, err
+	}
+	return m.detections, nil
+}
+
+func (m *manifests) compute() error {
+	if m.computed {
+		return nil
+	}
+
+	data, err := Render(m.config.Path)
+	if err != nil {
+		return err
+	}
+
+	detections, err := tchart.DetectImages(bytes.NewReader(data), m.config.ImagePaths...)
+	if err != nil {
+		return fmt.Errorf("detect images in %s: %w", m.config.Path, err)
+	}
+	m.detections = detections
+
+	for _, img := range tchart.Images(detections).Spec.Images {
+		i, err := content.NewImage(img.Name, m.remoteOpts...)
+		if err != nil {
+			return err
+		}
+		m.contents[img.Name] = i
+	}
+
+	ref, err := reference.NewTagged(m.config.Name, reference.DefaultTag)
+	if err != nil {
+		return err
+	}
+	m.contents[ref.Name()] = content.NewGenerated(m.config.Path, m.config.Name, data)
+
+	m.computed = true
+	return nil
+}
+
+// Render returns the manifests at path as a single yaml stream: a kustomization directory is built with kustomize, the
+// .yaml, .yml and .json files of any other directory are concatenated in name order, and a single file is read as is
+func Render(path string) ([]byte, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		return os.ReadFile(path)
+	}
+
+	if IsKustomization(path) {
+		k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+		rm, err := k.Run(filesys.MakeFsOnDisk(), path)
+		if err != nil {
+			return nil, fmt.Errorf("build kustomization %s: %w", path, err)
+		}
+		return rm.AsYaml()
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no manifests found in %s", path)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, n := range names {
+		data, err := os.ReadFile(filepath.Join(path, n))
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString("---\n")
+		buf.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// IsKustomization reports whether dir holds a kustomization file
+func IsKustomization(dir string) bool {
+	for _, n := range konfig.RecognizedKustomizationFileNames() {
+		if _, err := os.Stat(filepath.Join(dir, n)); err == nil {
+			return true
+		}
+	}
+	return false
+}
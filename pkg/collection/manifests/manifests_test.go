@@ -0,0 +1,172 @@
+package manifests_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/collection/manifests"
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: podinfo
+spec:
+  template:
+    spec:
+      containers:
+      - name: podinfo
+        image: %s/stefanprodan/podinfo:6.0.0
+`
+
+const cronJob = `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: cleanup
+            image: %s/library/busybox:1.35
+`
+
+func writeFile(t *testing.T, path string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManifestsContents(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	for _, image := range []string{"stefanprodan/podinfo:6.0.0", "stefanprodan/podinfo:6.0.3", "library/busybox:1.35"} {
+		ref, err := name.ParseReference(host + "/" + image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, empty.Image); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tmpdir := t.TempDir()
+
+	plain := filepath.Join(tmpdir, "plain")
+	writeFile(t, filepath.Join(plain, "deployment.yaml"), fmt.Sprintf(deployment, host))
+	writeFile(t, filepath.Join(plain, "cronjob.yml"), fmt.Sprintf(cronJob, host))
+	writeFile(t, filepath.Join(plain, "README.md"), "not a manifest")
+
+	kustomized := filepath.Join(tmpdir, "overlay")
+	writeFile(t, filepath.Join(tmpdir, "base", "deployment.yaml"), fmt.Sprintf(deployment, host))
+	writeFile(t, filepath.Join(tmpdir, "base", "kustomization.yaml"), "resources:\n- deployment.yaml\n")
+	writeFile(t, filepath.Join(kustomized, "kustomization.yaml"),
+		fmt.Sprintf("resources:\n- ../base\nimages:\n- name: %s/stefanprodan/podinfo\n  newTag: 6.0.3\n", host))
+
+	tests := []struct {
+		name     string
+		cfg      v1alpha1.Manifest
+		want     []string
+		rendered string
+	}{
+		{
+			name: "should store a single manifest and its images",
+			cfg:  v1alpha1.Manifest{Path: filepath.Join(plain, "deployment.yaml")},
+			want: []string{
+				"hauler/deployment.yaml:latest",
+				host + "/stefanprodan/podinfo:6.0.0",
+			},
+			rendered: "podinfo:6.0.0",
+		},
+		{
+			name: "should store a directory of manifests and their images",
+			cfg:  v1alpha1.Manifest{Path: plain, Name: "workloads.yaml"},
+			want: []string{
+				"hauler/workloads.yaml:latest",
+				host + "/library/busybox:1.35",
+				host + "/stefanprodan/podinfo:6.0.0",
+			},
+			rendered: "busybox:1.35",
+		},
+		{
+			name: "should render a kustomization and store its images",
+			cfg:  v1alpha1.Manifest{Path: kustomized},
+			want: []string{
+				"hauler/overlay.yaml:latest",
+				host + "/stefanprodan/podinfo:6.0.3",
+			},
+			rendered: "podinfo:6.0.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := manifests.NewManifests(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contents, err := m.Contents()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for ref := range contents {
+				got = append(got, ref)
+			}
+			sort.Strings(got)
+
+			// The rendered manifests are listed first
+			stored := tt.want[0]
+			want := append([]string{}, tt.want...)
+			sort.Strings(want)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Contents() = %v, want %v", got, want)
+			}
+
+			g, ok := contents[stored].(*content.Generated)
+			if !ok {
+				t.Fatalf("%s is a %T, want *content.Generated", stored, contents[stored])
+			}
+			layers, err := g.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := layers[0].Uncompressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			data, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.rendered) {
+				t.Errorf("rendered manifests do not contain %s:\n%s", tt.rendered, data)
+			}
+		})
+	}
+}
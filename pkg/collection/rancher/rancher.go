@@ -0,0 +1,182 @@
+package rancher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"github.com/rancherfederal/ocil/pkg/artifacts/file"
+	"helm.sh/helm/v3/pkg/action"
+
+	"github.com/rancherfederal/hauler/internal/release"
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/collection/imagetxt"
+	"github.com/rancherfederal/hauler/pkg/content/chart"
+	"github.com/rancherfederal/hauler/pkg/reference"
+)
+
+var _ artifacts.OCICollection = (*rancher)(nil)
+
+const (
+	DefaultReleaseURL   = "https://github.com/rancher/rancher/releases/download"
+	DefaultChartRepoURL = "https://releases.rancher.com/server-charts/latest"
+
+	ChartName = "rancher"
+)
+
+// DefaultImageLists are the image lists of a release collected when none are given
+var DefaultImageLists = []string{"rancher-images.txt", "rancher-windows-images.txt"}
+
+var ErrImageListNotFound = errors.New("rancher image list not found")
+
+// Endpoints locates rancher releases and the rancher chart, each defaults to upstream when empty.  Rancher doesn't
+// publish channels or an install script, so only the release location and client of the release endpoints are used.
+type Endpoints struct {
+	release.Endpoints
+
+	ChartRepo string
+}
+
+func (e Endpoints) withDefaults() Endpoints {
+	e.Endpoints = e.Endpoints.WithDefaults(release.Endpoints{Release: DefaultReleaseURL})
+	if e.ChartRepo == "" {
+		e.ChartRepo = DefaultChartRepoURL
+	}
+	return e
+}
+
+type rancher struct {
+	version    string
+	imageLists []string
+	sources    v1alpha1.ImageTxtSources
+	endpoints  Endpoints
+	chart      *chart.Chart
+
+	lock       sync.Mutex
+	computed   bool
+	contents   map[string]artifacts.OCI
+	remoteOpts []remote.Option
+}
+
+// NewRancher returns the collection of a rancher release: the rancher chart of the same version, the release's image
+// lists and every image they list, filtered by sources when the lists name their images' sources.  The chart is fetched
+// with opts (credentials and verification, if any) from the chart repository of endpoints, and the images (and charts stored in
+// OCI registries) are pulled with the given remote options.
+func NewRancher(version string, imageLists []string, sources v1alpha1.ImageTxtSources, endpoints Endpoints, opts *action.ChartPathOptions, ropts ...remote.Option) (artifacts.OCICollection, error) {
+	if len(imageLists) == 0 {
+		imageLists = DefaultImageLists
+	}
+	endpoints = endpoints.withDefaults()
+
+	var copts action.ChartPathOptions
+	if opts != nil {
+		copts = *opts
+	}
+	copts.Version = ChartVersion(version)
+
+	name := ChartName
+	if chart.IsOCI(endpoints.ChartRepo) {
+		name = strings.TrimSuffix(endpoints.ChartRepo, "/") + "/" + ChartName
+		copts.RepoURL = ""
+	} else {
+		copts.RepoURL = endpoints.ChartRepo
+	}
+
+	ch, err := chart.NewChart(name, &copts, ropts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rancher{
+		version:    "v" + ChartVersion(version),
+		imageLists: imageLists,
+		sources:    sources,
+		endpoints:  endpoints,
+		chart:      ch,
+		contents:   make(map[string]artifacts.OCI),
+		remoteOpts: ropts,
+	}, nil
+}
+
+// ChartVersion returns the version of the rancher chart of a release, the release's version without its v prefix
+func ChartVersion(version string) string {
+	return strings.TrimPrefix(version, "v")
+}
+
+func (r *rancher) Contents() (map[string]artifacts.OCI, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.compute(); err != nil {
+		return nil, err
+	}
+	return r.contents, nil
+}
+
+func (r *rancher) compute() error {
+	if r.computed {
+		return nil
+	}
+
+	if err := r.chartContents(); err != nil {
+		return err
+	}
+
+	for _, list := range r.imageLists {
+		if err := r.images(list); err != nil {
+			return err
+		}
+	}
+
+	r.computed = true
+	return nil
+}
+
+func (r *rancher) chartContents() error {
+	ch, err := r.chart.Load()
+	if err != nil {
+		return err
+	}
+
+	ref, err := reference.NewTagged(ch.Name(), ch.Metadata.Version)
+	if err != nil {
+		return err
+	}
+	r.contents[ref.Name()] = r.chart
+	return nil
+}
+
+// images adds an image list of the release and the images it lists, the list is fetched with the client of the
+// release endpoints
+func (r *rancher) images(list string) error {
+	u := r.endpoints.ReleaseURL(r.version, list)
+	rc, err := release.Get(r.endpoints.Client, u)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrImageListNotFound, err)
+	}
+	defer rc.Close()
+
+	it, err := imagetxt.New(u,
+		imagetxt.WithContent(rc),
+		imagetxt.WithIncludeSources(r.sources.Include...),
+		imagetxt.WithExcludeSources(r.sources.Exclude...),
+		imagetxt.WithRemoteOptions(r.remoteOpts...),
+	)
+	if err != nil {
+		return err
+	}
+
+	contents, err := it.Contents()
+	if err != nil {
+		return fmt.Errorf("%s: %w", list, err)
+	}
+	for ref, o := range contents {
+		r.contents[ref] = o
+	}
+
+	ref := fmt.Sprintf("%s/%s:%s", reference.DefaultNamespace, list, r.version)
+	r.contents[ref] = file.NewFile(u)
+	return nil
+}
@@ -0,0 +1,161 @@
+package rancher_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/rancherfederal/hauler/internal/release/releasetest"
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/collection/rancher"
+)
+
+// newTestServer serves a chart repository holding the rancher chart 2.6.3 and the image lists of release v2.6.3, with
+// the listed images pushed to a test registry
+func newTestServer(t *testing.T) (rancher.Endpoints, string) {
+	srv := releasetest.NewServer(t)
+	images := srv.Images(t, "rancher/rancher:v2.6.3", "rancher/fleet:v0.3.8", "rancher/rancher-agent:v2.6.3", "rancher/wins:v0.1.3")
+
+	srv.Release("v2.6.3", map[string]string{
+		"rancher-images.txt":         fmt.Sprintf("%s\n%s\n", images[0], images[1]),
+		"rancher-windows-images.txt": fmt.Sprintf("%s\n%s\n", images[2], images[3]),
+		"rancher-images-sources.txt": fmt.Sprintf("%s core\n%s fleet\n", images[0], images[1]),
+	})
+
+	ch := &helmchart.Chart{Metadata: &helmchart.Metadata{APIVersion: helmchart.APIVersionV2, Name: "rancher", Version: "2.6.3"}}
+	tgz, err := chartutil.Save(ch, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Handle("/charts/index.yaml", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "apiVersion: v1\nentries:\n  rancher:\n  - apiVersion: v2\n    name: rancher\n    version: 2.6.3\n    urls:\n    - rancher-2.6.3.tgz\n")
+	}))
+	srv.Handle("/charts/rancher-2.6.3.tgz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, tgz)
+	}))
+
+	// Keep helm's repository cache out of the user's home
+	t.Setenv("HELM_CACHE_HOME", t.TempDir())
+	t.Setenv("HELM_CONFIG_HOME", t.TempDir())
+
+	return rancher.Endpoints{Endpoints: srv.Endpoints(), ChartRepo: srv.URL + "/charts"}, srv.Registry
+}
+
+func TestRancherContents(t *testing.T) {
+	endpoints, host := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		imageLists []string
+		sources    v1alpha1.ImageTxtSources
+		want       []string
+	}{
+		{
+			name: "should collect the chart, the default image lists and their images",
+			want: []string{
+				"hauler/rancher-images.txt:v2.6.3",
+				"hauler/rancher-windows-images.txt:v2.6.3",
+				host + "/rancher/fleet:v0.3.8",
+				host + "/rancher/rancher-agent:v2.6.3",
+				host + "/rancher/rancher:v2.6.3",
+				host + "/rancher/wins:v0.1.3",
+			},
+		},
+		{
+			name:       "should filter images by source",
+			imageLists: []string{"rancher-images-sources.txt"},
+			sources:    v1alpha1.ImageTxtSources{Include: []string{"core"}},
+			want: []string{
+				"hauler/rancher-images-sources.txt:v2.6.3",
+				host + "/rancher/rancher:v2.6.3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := rancher.NewRancher("v2.6.3", tt.imageLists, tt.sources, endpoints, &action.ChartPathOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contents, err := r.Contents()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for ref := range contents {
+				got = append(got, ref)
+			}
+			sort.Strings(got)
+
+			want := append([]string{"hauler/rancher:2.6.3"}, tt.want...)
+			sort.Strings(want)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Contents() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRancherMissingImageList(t *testing.T) {
+	endpoints, _ := newTestServer(t)
+
+	// Chart options are optional
+	r, err := rancher.NewRancher("2.6.3", []string{"rancher-missing.txt"}, v1alpha1.ImageTxtSources{}, endpoints, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Contents(); !errors.Is(err, rancher.ErrImageListNotFound) {
+		t.Errorf("Contents() error = %v, want %v", err, rancher.ErrImageListNotFound)
+	}
+}
+
+func TestRancherClient(t *testing.T) {
+	endpoints, _ := newTestServer(t)
+
+	// A release server that finds every image list, but never sends one
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	endpoints.Release = srv.URL
+	endpoints.Client = &http.Client{Timeout: 50 * time.Millisecond}
+
+	r, err := rancher.NewRancher("2.6.3", nil, v1alpha1.ImageTxtSources{}, endpoints, &action.ChartPathOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Contents()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Contents() succeeded, want the image list download to time out")
+		}
+	case <-time.After(5 * time.Second):
+		srv.CloseClientConnections()
+		t.Fatal("Contents() didn't time out downloading the image list")
+	}
+}
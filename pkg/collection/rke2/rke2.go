@@ -0,0 +1,155 @@
+package rke2
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"github.com/rancherfederal/ocil/pkg/artifacts/file"
+	"github.com/rancherfederal/ocil/pkg/artifacts/file/getter"
+
+	"github.com/rancherfederal/hauler/internal/release"
+	"github.com/rancherfederal/hauler/pkg/content"
+	"github.com/rancherfederal/hauler/pkg/reference"
+)
+
+var _ artifacts.OCICollection = (*rke2)(nil)
+
+const (
+	DefaultReleaseURL       = "https://github.com/rancher/rke2/releases/download"
+	DefaultChannelURL       = "https://update.rke2.io/v1-release/channels"
+	DefaultInstallScriptURL = "https://get.rke2.io"
+
+	DefaultArch = "amd64"
+)
+
+var (
+	ErrImagesNotFound   = errors.New("rke2 dependent images not found")
+	ErrArtifactNotFound = errors.New("rke2 release artifact not found")
+)
+
+// DefaultEndpoints are the upstream rke2 release, channel and install script locations
+var DefaultEndpoints = release.Endpoints{
+	Release:       DefaultReleaseURL,
+	Channel:       DefaultChannelURL,
+	InstallScript: DefaultInstallScriptURL,
+}
+
+type rke2 struct {
+	version   string
+	arch      string
+	endpoints release.Endpoints
+
+	lock       sync.Mutex
+	computed   bool
+	contents   map[string]artifacts.OCI
+	remoteOpts []remote.Option
+}
+
+// NewRKE2 returns the collection of an rke2 release for arch: its release tarball, checksums, image list and install
+// script, along with every image of the image list, which are pulled with the given remote options.  The version is
+// expected to be resolved from a channel already, and endpoints that are empty default to upstream.
+func NewRKE2(version string, arch string, endpoints release.Endpoints, opts ...remote.Option) (artifacts.OCICollection, error) {
+	if arch == "" {
+		arch = DefaultArch
+	}
+	return &rke2{
+		version:    version,
+		arch:       arch,
+		endpoints:  endpoints.WithDefaults(DefaultEndpoints),
+		contents:   make(map[string]artifacts.OCI),
+		remoteOpts: opts,
+	}, nil
+}
+
+func (r *rke2) Contents() (map[string]artifacts.OCI, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.compute(); err != nil {
+		return nil, err
+	}
+	return r.contents, nil
+}
+
+func (r *rke2) compute() error {
+	if r.computed {
+		return nil
+	}
+
+	if err := r.images(); err != nil {
+		return err
+	}
+
+	for _, artifact := range []string{
+		fmt.Sprintf("rke2.linux-%s.tar.gz", r.arch),
+		fmt.Sprintf("sha256sum-%s.txt", r.arch),
+		r.imagesTxt(),
+	} {
+		if err := r.releaseFile(artifact); err != nil {
+			return err
+		}
+	}
+
+	if err := r.installScript(); err != nil {
+		return err
+	}
+
+	r.computed = true
+	return nil
+}
+
+// releaseFile adds an artifact of the release, tagged with the release's version
+func (r *rke2) releaseFile(artifact string) error {
+	u := r.endpoints.ReleaseURL(r.version, artifact)
+	if err := release.Exists(r.endpoints.Client, u); err != nil {
+		return fmt.Errorf("%w: %v", ErrArtifactNotFound, err)
+	}
+
+	ref := fmt.Sprintf("%s/%s:%s", reference.DefaultNamespace, artifact, r.dnsCompliantVersion())
+	r.contents[ref] = file.NewFile(u)
+	return nil
+}
+
+func (r *rke2) installScript() error {
+	c := getter.NewClient(getter.ClientOptions{NameOverride: "rke2-install.sh"})
+	f := file.NewFile(r.endpoints.InstallScript, file.WithClient(c))
+
+	ref := fmt.Sprintf("%s/rke2-install.sh:%s", reference.DefaultNamespace, reference.DefaultTag)
+	r.contents[ref] = f
+	return nil
+}
+
+func (r *rke2) images() error {
+	rc, err := release.Get(r.endpoints.Client, r.endpoints.ReleaseURL(r.version, r.imagesTxt()))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrImagesNotFound, err)
+	}
+	defer rc.Close()
+
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		ref := strings.TrimSpace(scanner.Text())
+		if ref == "" {
+			continue
+		}
+
+		o, err := content.NewImage(ref, r.remoteOpts...)
+		if err != nil {
+			return err
+		}
+		r.contents[ref] = o
+	}
+	return scanner.Err()
+}
+
+func (r *rke2) imagesTxt() string {
+	return fmt.Sprintf("rke2-images.linux-%s.txt", r.arch)
+}
+
+func (r *rke2) dnsCompliantVersion() string {
+	return strings.ReplaceAll(r.version, "+", "-")
+}
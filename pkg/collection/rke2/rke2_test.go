@@ -0,0 +1,71 @@
+package rke2_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/rancherfederal/hauler/internal/release/releasetest"
+	"github.com/rancherfederal/hauler/pkg/collection/rke2"
+)
+
+const version = "v1.22.5+rke2r1"
+
+// images are listed by the amd64 image list of the release
+var images = []string{"rancher/rke2-runtime:v1.22.5-rke2r1", "rancher/pause:3.5"}
+
+func TestRKE2Contents(t *testing.T) {
+	srv, refs := releasetest.NewRelease(t, version, "rke2-images.linux-amd64.txt", images, "rke2.linux-amd64.tar.gz", "sha256sum-amd64.txt")
+
+	r, err := rke2.NewRKE2(version, "", srv.Endpoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := r.Contents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for ref := range contents {
+		got = append(got, ref)
+	}
+	sort.Strings(got)
+
+	want := append([]string{
+		"hauler/rke2-images.linux-amd64.txt:v1.22.5-rke2r1",
+		"hauler/rke2-install.sh:latest",
+		"hauler/rke2.linux-amd64.tar.gz:v1.22.5-rke2r1",
+		"hauler/sha256sum-amd64.txt:v1.22.5-rke2r1",
+	}, refs...)
+	sort.Strings(want)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Contents() = %v, want %v", got, want)
+	}
+
+	m, err := contents["hauler/rke2-install.sh:latest"].Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title := m.Layers[0].Annotations[ocispec.AnnotationTitle]; title != "rke2-install.sh" {
+		t.Errorf("install script title = %s, want rke2-install.sh", title)
+	}
+}
+
+func TestRKE2MissingArch(t *testing.T) {
+	srv, _ := releasetest.NewRelease(t, version, "rke2-images.linux-amd64.txt", images, "rke2.linux-amd64.tar.gz", "sha256sum-amd64.txt")
+
+	r, err := rke2.NewRKE2(version, "arm64", srv.Endpoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Contents(); !errors.Is(err, rke2.ErrImagesNotFound) {
+		t.Errorf("Contents() error = %v, want %v", err, rke2.ErrImagesNotFound)
+	}
+}
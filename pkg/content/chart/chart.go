@@ -12,6 +12,7 @@ import (
 
 	gv1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	gtypes "github.com/google/go-containerregistry/pkg/v1/types"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/rancherfederal/ocil/pkg/artifacts"
@@ -32,10 +33,18 @@ var _ artifacts.OCI = (*Chart)(nil)
 type Chart struct {
 	path        string
 	annotations map[string]string
+
+	// prov is the path to the chart's provenance file, if it has one
+	prov string
 }
 
-// NewChart is a helper method that returns NewLocalChart or NewRemoteChart depending on v1alpha1.Chart contents
-func NewChart(name string, opts *action.ChartPathOptions) (*Chart, error) {
+// NewChart is a helper method that returns NewLocalChart or NewRemoteChart depending on v1alpha1.Chart contents.
+// Charts named oci://registry/repository/chart are pulled from OCI registries with the given remote options.
+func NewChart(name string, opts *action.ChartPathOptions, ropts ...remote.Option) (*Chart, error) {
+	if IsOCI(name) {
+		return newOCIChart(name, opts, ropts...)
+	}
+
 	cpo := action.ChartPathOptions{
 		RepoURL: opts.RepoURL,
 		Version: opts.Version,
@@ -51,13 +60,25 @@ func NewChart(name string, opts *action.ChartPathOptions) (*Chart, error) {
 		Verify:                opts.Verify,
 	}
 
+	_, statErr := os.Stat(name)
+	local := statErr == nil
+
 	chartPath, err := cpo.LocateChart(name, cli.New())
 	if err != nil {
 		return nil, err
 	}
 
+	// Provenance files sit beside local charts, and are downloaded beside remote charts when verifying them
+	var prov string
+	if local || opts.Verify {
+		if _, err := os.Stat(chartPath + ".prov"); err == nil {
+			prov = chartPath + ".prov"
+		}
+	}
+
 	return &Chart{
 		path: chartPath,
+		prov: prov,
 	}, err
 }
 
@@ -126,10 +147,15 @@ func (h *Chart) Layers() ([]gv1.Layer, error) {
 		return nil, err
 	}
 
-	return []gv1.Layer{
-		chartDataLayer,
-		// TODO: Add provenance
-	}, nil
+	layers := []gv1.Layer{chartDataLayer}
+	if h.prov != "" {
+		provLayer, err := h.provData()
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, provLayer)
+	}
+	return layers, nil
 }
 
 func (h *Chart) RawChartData() ([]byte, error) {
@@ -216,3 +242,23 @@ func (h *Chart) chartData() (gv1.Layer, error) {
 
 	return chartDataLayer, err
 }
+
+// provData returns the chart's provenance file as a layer, titled after the chart archive so it's extracted beside it
+func (h *Chart) provData() (gv1.Layer, error) {
+	data, err := os.ReadFile(h.prov)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := make(map[string]string)
+	annotations[ocispec.AnnotationTitle] = filepath.Base(h.path) + ".prov"
+
+	opener := func() layer.Opener {
+		return func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewBuffer(data)), nil
+		}
+	}
+	return layer.FromOpener(opener(),
+		layer.WithMediaType(consts.ProvLayerMediaType),
+		layer.WithAnnotations(annotations))
+}
@@ -2,6 +2,7 @@ package chart_test
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -102,7 +103,7 @@ func TestNewChart(t *testing.T) {
 				t.Error(err)
 			}
 
-			// TODO: This changes when we support provenance files
+			// Charts without a provenance file only have the chart layer
 			if len(m.Layers) > 1 {
 				t.Errorf("Expected 1 layer for chart, got %d", len(m.Layers))
 			}
@@ -115,3 +116,47 @@ func TestNewChart(t *testing.T) {
 		})
 	}
 }
+
+func TestNewChartProvenance(t *testing.T) {
+	tmpdir := t.TempDir()
+
+	data, err := os.ReadFile(chartpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tmpdir, "podinfo-6.0.3.tgz")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".prov", []byte("provenance"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := chart.NewChart(path, &action.ChartPathOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := c.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Layers) != 2 {
+		t.Fatalf("Expected 2 layers for chart with provenance, got %d", len(m.Layers))
+	}
+
+	want := v1.Descriptor{
+		MediaType: consts.ProvLayerMediaType,
+		Size:      10,
+		Digest: v1.Hash{
+			Algorithm: "sha256",
+			Hex:       "96d815328a42cb4ef89d5e0b7a1df6be43b484832c83a7b4596d8402c7c0b12b",
+		},
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle: "podinfo-6.0.3.tgz.prov",
+		},
+	}
+	if !reflect.DeepEqual(m.Layers[1], want) {
+		t.Errorf("got: %v\nwant: %v", m.Layers[1], want)
+	}
+}
@@ -0,0 +1,166 @@
+package chart
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rancherfederal/ocil/pkg/consts"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/downloader"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+// OCIScheme prefixes the names of charts stored in OCI registries
+const OCIScheme = "oci://"
+
+// IsOCI returns whether a chart name refers to a chart stored in an OCI registry
+func IsOCI(name string) bool {
+	return strings.HasPrefix(name, OCIScheme)
+}
+
+// newOCIChart pulls a chart from an OCI registry into helm's repository cache, the same way helm stores the charts it
+// downloads from repositories.  The chart's version is resolved from the repository's tags like helm does, and a
+// provenance file is pulled with the chart when it has one.
+func newOCIChart(ref string, opts *action.ChartPathOptions, ropts ...remote.Option) (*Chart, error) {
+	repo, err := name.NewRepository(strings.TrimPrefix(ref, OCIScheme))
+	if err != nil {
+		return nil, err
+	}
+
+	// Explicit credentials take precedence over any keychain
+	if opts.Username != "" || opts.Password != "" {
+		ropts = append([]remote.Option{remote.WithAuth(&authn.Basic{Username: opts.Username, Password: opts.Password})}, ropts...)
+	}
+
+	version, err := resolveOCIVersion(repo, opts.Version, ropts...)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := content.NewImage(repo.Tag(strings.ReplaceAll(version, "+", "_")).Name(), ropts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := cli.New()
+	if err := os.MkdirAll(settings.RepositoryCache, 0755); err != nil {
+		return nil, err
+	}
+
+	chartName := repo.RepositoryStr()[strings.LastIndex(repo.RepositoryStr(), "/")+1:]
+	path := filepath.Join(settings.RepositoryCache, fmt.Sprintf("%s-%s.tgz", chartName, version))
+
+	var prov string
+	var found bool
+	for _, desc := range m.Layers {
+		dest := ""
+		switch string(desc.MediaType) {
+		case consts.ChartLayerMediaType:
+			dest, found = path, true
+		case consts.ProvLayerMediaType:
+			dest, prov = path+".prov", path+".prov"
+		default:
+			continue
+		}
+
+		if err := writeLayer(img.Image, desc.Digest, dest); err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s:%s is not a helm chart", repo, version)
+	}
+
+	if opts.Verify {
+		if prov == "" {
+			return nil, fmt.Errorf("%s:%s has no provenance file to verify", repo, version)
+		}
+		if _, err := downloader.VerifyChart(path, opts.Keyring); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Chart{
+		path: path,
+		prov: prov,
+	}, nil
+}
+
+// resolveOCIVersion returns the newest version tagged in repo matching constraint, or the constraint itself when it's
+// an exact version.  Without a constraint the newest stable version is returned.
+func resolveOCIVersion(repo name.Repository, constraint string, ropts ...remote.Option) (string, error) {
+	if _, err := semver.StrictNewVersion(constraint); err == nil {
+		return constraint, nil
+	}
+
+	versions, err := ociVersions(repo, constraint, ropts...)
+	if err != nil {
+		return "", err
+	}
+	return versions[0], nil
+}
+
+// ociVersions returns every version tagged in repo matching constraint, newest first
+func ociVersions(repo name.Repository, constraint string, ropts ...remote.Option) ([]string, error) {
+	tags, err := remote.List(repo, ropts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Helm tags versions with build metadata using _ in place of +, which tags can't contain
+	for i, t := range tags {
+		tags[i] = strings.ReplaceAll(t, "_", "+")
+	}
+
+	return matchVersions(repo.String(), tags, constraint)
+}
+
+// writeLayer writes the layer of img with digest d to dest
+func writeLayer(img gv1.Image, d gv1.Hash, dest string) error {
+	l, err := img.LayerByDigest(d)
+	if err != nil {
+		return err
+	}
+
+	rc, err := l.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Write to a temporary file in the cache first and move it into place, so charts pulled concurrently never see each
+	// other's partially written files
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
@@ -0,0 +1,165 @@
+package chart_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"helm.sh/helm/v3/pkg/action"
+
+	"github.com/rancherfederal/ocil/pkg/consts"
+
+	"github.com/rancherfederal/hauler/pkg/content/chart"
+)
+
+func TestNewChartOCI(t *testing.T) {
+	t.Setenv("HELM_REPOSITORY_CACHE", t.TempDir())
+
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	repo := strings.TrimPrefix(srv.URL, "http://") + "/charts/podinfo"
+
+	data, err := os.ReadFile(chartpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the contents of the chart tagged 6.0.3 are a valid chart, the rest are only told apart by their layers
+	layers := map[string][][]byte{
+		"5.0.0":       {[]byte("5.0.0")},
+		"6.0.1_build": {[]byte("6.0.1+build")},
+		"6.0.3":       {data, []byte("provenance")},
+		"6.0.4-rc.1":  {[]byte("6.0.4-rc.1")},
+		"latest":      {[]byte("latest")},
+	}
+	for tag, ls := range layers {
+		adds := []mutate.Addendum{{Layer: static.NewLayer(ls[0], consts.ChartLayerMediaType)}}
+		if len(ls) > 1 {
+			adds = append(adds, mutate.Addendum{Layer: static.NewLayer(ls[1], consts.ProvLayerMediaType)})
+		}
+
+		img, err := mutate.Append(empty.Image, adds...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := name.ParseReference(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		version    string
+		wantLayers [][]byte
+		wantErr    bool
+	}{
+		{
+			name:       "newest stable version",
+			wantLayers: layers["6.0.3"],
+		},
+		{
+			name:       "version constraint",
+			version:    "^5.0.0",
+			wantLayers: layers["5.0.0"],
+		},
+		{
+			name:       "prerelease constraint",
+			version:    ">=6.0.4-0",
+			wantLayers: layers["6.0.4-rc.1"],
+		},
+		{
+			name:       "exact version with build metadata",
+			version:    "6.0.1+build",
+			wantLayers: layers["6.0.1_build"],
+		},
+		{
+			name:    "no matching version",
+			version: ">7.0.0",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := chart.NewChart("oci://"+repo, &action.ChartPathOptions{Version: tt.version})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewChart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got, err := c.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.wantLayers) {
+				t.Fatalf("Expected %d layers, got %d", len(tt.wantLayers), len(got))
+			}
+			for i, l := range got {
+				d, err := l.Digest()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _, err := v1.SHA256(strings.NewReader(string(tt.wantLayers[i])))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d != want {
+					t.Errorf("layer %d: got %s, want %s", i, d, want)
+				}
+			}
+		})
+	}
+
+	// The chart tagged 6.0.3 is podinfo itself, and pulling it concurrently always leaves a whole chart in the cache
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = chart.NewChart("oci://"+repo, &action.ChartPathOptions{Version: "6.0.3"})
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := chart.NewChart("oci://"+repo, &action.ChartPathOptions{Version: "6.0.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Name() != "podinfo" || ch.Metadata.Version != "6.0.3" {
+		t.Errorf("got chart %s %s, want podinfo 6.0.3", ch.Name(), ch.Metadata.Version)
+	}
+
+	cached, err := filepath.Glob(filepath.Join(os.Getenv("HELM_REPOSITORY_CACHE"), "podinfo-6.0.3.tgz*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 2 {
+		t.Errorf("cached files = %v, want only the chart and its provenance file", cached)
+	}
+}
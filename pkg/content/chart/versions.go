@@ -0,0 +1,127 @@
+package chart
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// Versions returns every version of a chart matching the version constraint of opts, newest first, keeping only the
+// newest max versions when max is greater than zero.  Versions are read from the index of the chart's repository, or
+// from the tags of charts stored in OCI registries, which are listed with the given remote options.
+func Versions(chartName string, opts *action.ChartPathOptions, max int, ropts ...remote.Option) ([]string, error) {
+	var versions []string
+	switch {
+	case IsOCI(chartName):
+		r, err := name.NewRepository(chartName[len(OCIScheme):])
+		if err != nil {
+			return nil, err
+		}
+
+		vs, err := ociVersions(r, opts.Version, ropts...)
+		if err != nil {
+			return nil, err
+		}
+		versions = vs
+
+	case opts.RepoURL != "":
+		vs, err := repoVersions(chartName, opts)
+		if err != nil {
+			return nil, err
+		}
+		versions = vs
+
+	default:
+		return nil, fmt.Errorf("chart %s: versions can only be listed from a repository", chartName)
+	}
+
+	if max > 0 && len(versions) > max {
+		versions = versions[:max]
+	}
+	return versions, nil
+}
+
+// repoVersions returns every version of a chart in its repository's index matching the version constraint of opts
+func repoVersions(chartName string, opts *action.ChartPathOptions) ([]string, error) {
+	settings := cli.New()
+
+	cr, err := repo.NewChartRepository(&repo.Entry{
+		URL:                   opts.RepoURL,
+		Username:              opts.Username,
+		Password:              opts.Password,
+		CertFile:              opts.CertFile,
+		KeyFile:               opts.KeyFile,
+		CAFile:                opts.CaFile,
+		InsecureSkipTLSverify: opts.InsecureSkipTLSverify,
+		PassCredentialsAll:    opts.PassCredentialsAll,
+	}, getter.All(settings))
+	if err != nil {
+		return nil, err
+	}
+
+	// The index is only needed once, so it isn't kept in helm's repository cache
+	tmp, err := os.MkdirTemp("", "hauler")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	cr.CachePath = tmp
+
+	path, err := cr.DownloadIndexFile()
+	if err != nil {
+		return nil, fmt.Errorf("looks like %q is not a valid chart repository or cannot be reached: %w", opts.RepoURL, err)
+	}
+
+	idx, err := repo.LoadIndexFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var available []string
+	for _, cv := range idx.Entries[chartName] {
+		available = append(available, cv.Version)
+	}
+	return matchVersions(chartName, available, opts.Version)
+}
+
+// matchVersions returns the versions matching constraint, newest first.  Without a constraint every stable version
+// matches.
+func matchVersions(chartName string, available []string, constraint string) ([]string, error) {
+	c := constraint
+	if c == "" {
+		c = "*"
+	}
+	cs, err := semver.NewConstraint(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart version constraint %s: %w", constraint, err)
+	}
+
+	var matched []*semver.Version
+	for _, a := range available {
+		v, err := semver.NewVersion(a)
+		if err != nil {
+			continue
+		}
+		if cs.Check(v) {
+			matched = append(matched, v)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no version of chart %s matches %s", chartName, c)
+	}
+
+	sort.Sort(sort.Reverse(semver.Collection(matched)))
+	versions := make([]string, len(matched))
+	for i, v := range matched {
+		versions[i] = v.Original()
+	}
+	return versions, nil
+}
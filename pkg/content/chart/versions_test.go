@@ -0,0 +1,101 @@
+package chart_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"helm.sh/helm/v3/pkg/action"
+
+	"github.com/rancherfederal/hauler/pkg/content/chart"
+)
+
+var available = []string{"2.5.12", "2.6.0", "2.6.1", "2.6.2", "2.6.3", "2.7.0-rc1", "2.7.0"}
+
+func TestVersions(t *testing.T) {
+	idx := strings.Builder{}
+	idx.WriteString("apiVersion: v1\nentries:\n  rancher:\n")
+	for _, v := range available {
+		fmt.Fprintf(&idx, "  - name: rancher\n    version: %s\n    urls:\n    - rancher-%s.tgz\n", v, v)
+	}
+	repoSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, idx.String())
+	}))
+	defer repoSrv.Close()
+
+	regSrv := httptest.NewServer(registry.New())
+	defer regSrv.Close()
+	repo := strings.TrimPrefix(regSrv.URL, "http://") + "/charts/rancher"
+	for _, v := range available {
+		ref, err := name.ParseReference(repo + ":" + v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, empty.Image); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		max        int
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:       "should select every matching version, newest first",
+			constraint: ">=2.6.0 <2.7.0",
+			want:       []string{"2.6.3", "2.6.2", "2.6.1", "2.6.0"},
+		},
+		{
+			name:       "should keep only the newest versions",
+			constraint: ">=2.6.0 <2.7.0",
+			max:        2,
+			want:       []string{"2.6.3", "2.6.2"},
+		},
+		{
+			name: "should select every stable version without a constraint",
+			want: []string{"2.7.0", "2.6.3", "2.6.2", "2.6.1", "2.6.0", "2.5.12"},
+		},
+		{
+			name:       "should fail when no version matches",
+			constraint: ">=3.0.0",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := map[string]func() ([]string, error){
+				"repository": func() ([]string, error) {
+					opts := &action.ChartPathOptions{RepoURL: repoSrv.URL, Version: tt.constraint}
+					return chart.Versions("rancher", opts, tt.max)
+				},
+				"oci": func() ([]string, error) {
+					opts := &action.ChartPathOptions{Version: tt.constraint}
+					return chart.Versions(chart.OCIScheme+repo, opts, tt.max)
+				},
+			}
+			for source, versions := range sources {
+				got, err := versions()
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Versions() from %s error = %v, wantErr %v", source, err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Versions() from %s = %v, want %v", source, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package content
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rancherfederal/ocil/pkg/artifacts/file/getter"
+)
+
+// ChecksumAnnotation records the verified sha256 checksum of a file's contents on its manifest
+const ChecksumAnnotation = "content.hauler.cattle.io/sha256"
+
+var sha256Pattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// ParseSHA256 normalizes a sha256 checksum given as hex, optionally prefixed with sha256:
+func ParseSHA256(checksum string) (string, error) {
+	c := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(checksum), "sha256:"))
+	if !sha256Pattern.MatchString(c) {
+		return "", fmt.Errorf("invalid sha256 checksum %q", checksum)
+	}
+	return c, nil
+}
+
+// FetchChecksum fetches a checksum file from a local or remote path and returns the checksum of filename in it
+func FetchChecksum(ctx context.Context, checksumURL string, filename string) (string, error) {
+	rc, err := getter.NewClient(getter.ClientOptions{}).ContentFrom(ctx, checksumURL)
+	if err != nil {
+		return "", fmt.Errorf("fetch checksums %s: %w", checksumURL, err)
+	}
+	defer rc.Close()
+
+	c, err := ParseChecksums(rc, filename)
+	if err != nil {
+		return "", fmt.Errorf("checksums %s: %w", checksumURL, err)
+	}
+	return c, nil
+}
+
+// ParseChecksums returns the sha256 checksum of filename from a checksum file in the format of sha256sum (and
+// SHA256SUMS files), where each line is a checksum followed by a file name.  A file holding nothing but a single
+// checksum is the checksum of filename.
+func ParseChecksums(r io.Reader, filename string) (string, error) {
+	var unnamed []string
+	entries := 0
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		c, err := ParseSHA256(fields[0])
+		if err != nil {
+			return "", err
+		}
+		entries++
+
+		if len(fields) < 2 {
+			unnamed = append(unnamed, c)
+			continue
+		}
+
+		// A leading * marks files checksummed in binary mode
+		name := strings.TrimPrefix(strings.Join(fields[1:], " "), "*")
+		if name == filename || path.Base(name) == filename {
+			return c, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+
+	if entries == 1 && len(unnamed) == 1 {
+		return unnamed[0], nil
+	}
+	return "", fmt.Errorf("no checksum of %s found", filename)
+}
+
+// ChecksumName returns the file name a checksum file refers to a file by, the last element of its local or remote path
+func ChecksumName(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return filepath.Base(source)
+}
@@ -0,0 +1,97 @@
+package content_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+const (
+	k3sSum    = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	imagesSum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+func TestParseChecksums(t *testing.T) {
+	sums := fmt.Sprintf("# generated\n%s  k3s-images.txt\n%s *dist/artifacts/k3s\n", imagesSum, k3sSum)
+
+	tests := []struct {
+		name     string
+		data     string
+		filename string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "should find a file's checksum",
+			data:     sums,
+			filename: "k3s-images.txt",
+			want:     imagesSum,
+		},
+		{
+			name:     "should find a binary file by its base name",
+			data:     sums,
+			filename: "k3s",
+			want:     k3sSum,
+		},
+		{
+			name:     "should use a lone checksum",
+			data:     strings.ToUpper(k3sSum) + "\n",
+			filename: "k3s",
+			want:     k3sSum,
+		},
+		{
+			name:     "should fail when the file isn't listed",
+			data:     sums,
+			filename: "k3s-airgap-images-amd64.tar",
+			wantErr:  true,
+		},
+		{
+			name:     "should fail on a lone checksum of another file",
+			data:     k3sSum + "  k3s-arm64\n",
+			filename: "k3s",
+			wantErr:  true,
+		},
+		{
+			name:     "should fail on an invalid checksum",
+			data:     "abc  k3s\n",
+			filename: "k3s",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := content.ParseChecksums(strings.NewReader(tt.data), tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChecksums() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseChecksums() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchChecksum(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  k3s\n", k3sSum)
+	}))
+	defer srv.Close()
+
+	filename := content.ChecksumName("https://github.com/k3s-io/k3s/releases/download/v1.22.5%2Bk3s1/k3s?raw=true")
+	if filename != "k3s" {
+		t.Fatalf("ChecksumName() = %s, want k3s", filename)
+	}
+
+	got, err := content.FetchChecksum(context.Background(), srv.URL+"/sha256sum-amd64.txt", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != k3sSum {
+		t.Errorf("FetchChecksum() = %s, want %s", got, k3sSum)
+	}
+}
@@ -10,6 +10,27 @@ import (
 	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
 )
 
+// kind describes a content or collection type that can be declared in a document
+type kind struct {
+	groupVersion schema.GroupVersion
+	new          func() interface{}
+}
+
+var kinds = map[string]kind{
+	v1alpha1.FilesContentKind:             {v1alpha1.ContentGroupVersion, func() interface{} { return &v1alpha1.Files{} }},
+	v1alpha1.ImagesContentKind:            {v1alpha1.ContentGroupVersion, func() interface{} { return &v1alpha1.Images{} }},
+	v1alpha1.ChartsContentKind:            {v1alpha1.ContentGroupVersion, func() interface{} { return &v1alpha1.Charts{} }},
+	v1alpha1.ImageRepositoriesContentKind: {v1alpha1.ContentGroupVersion, func() interface{} { return &v1alpha1.ImageRepositories{} }},
+	v1alpha1.GitsContentKind:              {v1alpha1.ContentGroupVersion, func() interface{} { return &v1alpha1.Gits{} }},
+	v1alpha1.ImageTxtsContentKind:         {v1alpha1.ContentGroupVersion, func() interface{} { return &v1alpha1.ImageTxts{} }},
+	v1alpha1.CredentialsContentKind:       {v1alpha1.ContentGroupVersion, func() interface{} { return &v1alpha1.Credentials{} }},
+	v1alpha1.ChartsCollectionKind:         {v1alpha1.CollectionGroupVersion, func() interface{} { return &v1alpha1.ThickCharts{} }},
+	v1alpha1.K3sCollectionKind:            {v1alpha1.CollectionGroupVersion, func() interface{} { return &v1alpha1.K3s{} }},
+	v1alpha1.RKE2CollectionKind:           {v1alpha1.CollectionGroupVersion, func() interface{} { return &v1alpha1.RKE2{} }},
+	v1alpha1.RancherCollectionKind:        {v1alpha1.CollectionGroupVersion, func() interface{} { return &v1alpha1.Rancher{} }},
+	v1alpha1.ManifestsCollectionKind:      {v1alpha1.CollectionGroupVersion, func() interface{} { return &v1alpha1.Manifests{} }},
+}
+
 func Load(data []byte) (schema.ObjectKind, error) {
 	var tm metav1.TypeMeta
 	if err := yaml.Unmarshal(data, &tm); err != nil {
@@ -0,0 +1,321 @@
+package content
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	gtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"github.com/rancherfederal/ocil/pkg/consts"
+	"github.com/rancherfederal/ocil/pkg/layer"
+)
+
+// DirectoryLayerMediaType is the media type of a directory's layer, a tar+gzip archive of the directory's tree
+const DirectoryLayerMediaType = "application/vnd.content.hauler.directory.layer.v1.tar+gzip"
+
+var _ artifacts.OCI = (*Directory)(nil)
+
+// Directory implements the OCI interface for a local directory, or the files and directories matching a glob, stored as
+// a single tar layer.  Paths in the archive are relative to the directory (or the directory the glob is rooted at) and
+// keep their modes, while ownership and times are dropped so that the same tree always produces the same layer.
+type Directory struct {
+	Path string
+
+	name     string
+	computed bool
+	config   artifacts.Config
+	blob     gv1.Layer
+	manifest *gv1.Manifest
+}
+
+type directoryConfig struct {
+	Reference string `json:"reference"`
+}
+
+// IsDirectory reports whether path is a local directory or a glob of local paths
+func IsDirectory(path string) bool {
+	if strings.Contains(path, "://") {
+		return false
+	}
+	if IsGlob(path) {
+		return true
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// IsGlob reports whether path is a glob rather than a literal path
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
+
+// NewDirectory returns the directory or glob at path, named name or otherwise after the directory
+func NewDirectory(path string, name string) *Directory {
+	if name == "" {
+		name = directoryRoot(path)
+		if abs, err := filepath.Abs(name); err == nil {
+			name = abs
+		}
+		name = filepath.Base(name)
+	}
+	return &Directory{Path: path, name: name}
+}
+
+// Name is the name of the directory's reference
+func (d *Directory) Name() string {
+	return d.name
+}
+
+func (d *Directory) MediaType() string {
+	return consts.OCIManifestSchema1
+}
+
+func (d *Directory) RawConfig() ([]byte, error) {
+	if err := d.compute(); err != nil {
+		return nil, err
+	}
+	return d.config.Raw()
+}
+
+func (d *Directory) Layers() ([]gv1.Layer, error) {
+	if err := d.compute(); err != nil {
+		return nil, err
+	}
+	return []gv1.Layer{d.blob}, nil
+}
+
+func (d *Directory) Manifest() (*gv1.Manifest, error) {
+	if err := d.compute(); err != nil {
+		return nil, err
+	}
+	return d.manifest, nil
+}
+
+// Size returns the combined size of the regular files in the directory, without archiving them
+func (d *Directory) Size() (int64, error) {
+	files, err := d.files()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, f := range files {
+		if f.info.Mode().IsRegular() {
+			size += f.info.Size()
+		}
+	}
+	return size, nil
+}
+
+func (d *Directory) compute() error {
+	if d.computed {
+		return nil
+	}
+
+	// Fail early on globs matching nothing rather than storing an empty archive
+	if _, err := d.files(); err != nil {
+		return err
+	}
+
+	blob, err := layer.FromOpener(d.open,
+		layer.WithMediaType(DirectoryLayerMediaType),
+		layer.WithAnnotations(map[string]string{ocispec.AnnotationTitle: d.name}))
+	if err != nil {
+		return err
+	}
+
+	layerDesc, err := partial.Descriptor(blob)
+	if err != nil {
+		return err
+	}
+
+	cfg := artifacts.ToConfig(&directoryConfig{Reference: d.Path}, artifacts.WithConfigMediaType(consts.FileDirectoryConfigMediaType))
+	cfgDesc, err := partial.Descriptor(cfg)
+	if err != nil {
+		return err
+	}
+
+	d.manifest = &gv1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     gtypes.MediaType(d.MediaType()),
+		Config:        *cfgDesc,
+		Layers:        []gv1.Descriptor{*layerDesc},
+	}
+	d.config = cfg
+	d.blob = blob
+	d.computed = true
+	return nil
+}
+
+// open archives the directory, streaming the archive as it's written
+func (d *Directory) open() (io.ReadCloser, error) {
+	files, err := d.files()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTarGzip(pw, files))
+	}()
+	return pr, nil
+}
+
+type directoryFile struct {
+	path string
+	name string
+	info fs.FileInfo
+}
+
+// files returns every path to archive in a stable order, along with its name relative to the directory's root
+func (d *Directory) files() ([]directoryFile, error) {
+	root := directoryRoot(d.Path)
+
+	matches := []string{d.Path}
+	if IsGlob(d.Path) {
+		m, err := filepath.Glob(d.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %s: %w", d.Path, err)
+		}
+		if len(m) == 0 {
+			return nil, fmt.Errorf("glob %s does not match any path", d.Path)
+		}
+		matches = m
+	}
+
+	seen := make(map[string]bool)
+	var files []directoryFile
+	for _, match := range matches {
+		err := filepath.Walk(match, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			name, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if name == "." || seen[name] {
+				return nil
+			}
+			seen[name] = true
+
+			files = append(files, directoryFile{path: path, name: filepath.ToSlash(name), info: info})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parents of matched paths are added with their modes so that the archive's tree is complete
+	for _, f := range files {
+		for dir := filepath.Dir(f.name); dir != "."; dir = filepath.Dir(dir) {
+			if seen[dir] {
+				break
+			}
+			seen[dir] = true
+
+			info, err := os.Stat(filepath.Join(root, dir))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, directoryFile{path: filepath.Join(root, dir), name: filepath.ToSlash(dir), info: info})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// directoryRoot returns the directory paths are archived relative to: the directory itself, or the longest leading
+// directory of a glob without any pattern
+func directoryRoot(path string) string {
+	if !IsGlob(path) {
+		return filepath.Clean(path)
+	}
+
+	var static []string
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if IsGlob(part) {
+			break
+		}
+		static = append(static, part)
+	}
+
+	root := strings.Join(static, "/")
+	if root == "" && strings.HasPrefix(path, "/") {
+		return "/"
+	}
+	if root == "" {
+		return "."
+	}
+	return filepath.Clean(filepath.FromSlash(root))
+}
+
+func writeTarGzip(w io.Writer, files []directoryFile) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	for _, f := range files {
+		var link string
+		if f.info.Mode()&fs.ModeSymlink != 0 {
+			l, err := os.Readlink(f.path)
+			if err != nil {
+				return err
+			}
+			link = l
+		}
+
+		hdr, err := tar.FileInfoHeader(f.info, link)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		hdr.Name = f.name
+		if f.info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "", ""
+		hdr.ModTime = time.Unix(0, 0)
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !f.info.Mode().IsRegular() {
+			continue
+		}
+
+		if err := copyFile(tw, f.path); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,167 @@
+package content_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+func TestDirectory(t *testing.T) {
+	root := t.TempDir()
+	for path, mode := range map[string]os.FileMode{
+		"configs/app.yaml":    0644,
+		"configs/db.yaml":     0600,
+		"configs/run.sh":      0755,
+		"configs/rpms/a.rpm":  0644,
+		"configs/rpms/b.rpm":  0644,
+		"configs/rpms/readme": 0644,
+	} {
+		p := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(path), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		override string
+		wantName string
+		want     map[string]os.FileMode
+		wantErr  bool
+	}{
+		{
+			name:     "should archive a directory",
+			path:     filepath.Join(root, "configs"),
+			wantName: "configs",
+			want: map[string]os.FileMode{
+				"app.yaml":    0644,
+				"db.yaml":     0600,
+				"run.sh":      0755,
+				"rpms/":       0755 | os.ModeDir,
+				"rpms/a.rpm":  0644,
+				"rpms/b.rpm":  0644,
+				"rpms/readme": 0644,
+			},
+		},
+		{
+			name:     "should archive the paths matching a glob",
+			path:     filepath.Join(root, "configs", "*", "*.rpm"),
+			override: "packages",
+			wantName: "packages",
+			want: map[string]os.FileMode{
+				"rpms/":      0755 | os.ModeDir,
+				"rpms/a.rpm": 0644,
+				"rpms/b.rpm": 0644,
+			},
+		},
+		{
+			name:    "should fail on a glob matching nothing",
+			path:    filepath.Join(root, "*.iso"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !content.IsDirectory(tt.path) {
+				t.Fatalf("IsDirectory(%s) = false", tt.path)
+			}
+
+			d := content.NewDirectory(tt.path, tt.override)
+			layers, err := d.Layers()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Layers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if d.Name() != tt.wantName {
+				t.Errorf("Name() = %s, want %s", d.Name(), tt.wantName)
+			}
+
+			m, err := d.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if title := m.Layers[0].Annotations[ocispec.AnnotationTitle]; title != tt.wantName {
+				t.Errorf("layer title = %s, want %s", title, tt.wantName)
+			}
+
+			got := archived(t, layers[0].Compressed)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("archived %v, want %v", got, tt.want)
+			}
+
+			// Touching the tree mustn't change the layer, only its contents do
+			now := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(root, "configs", "rpms", "a.rpm"), now, now); err != nil {
+				t.Fatal(err)
+			}
+			again, err := content.NewDirectory(tt.path, tt.override).Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Layers[0].Digest != m.Layers[0].Digest {
+				t.Errorf("layer digest changed from %s to %s", m.Layers[0].Digest, again.Layers[0].Digest)
+			}
+		})
+	}
+}
+
+func TestIsDirectory(t *testing.T) {
+	for path, want := range map[string]bool{
+		"https://example.com/file?raw=true": false,
+		t.TempDir():                         true,
+		"render.go":                         false,
+		"*.go":                              true,
+	} {
+		if got := content.IsDirectory(path); got != want {
+			t.Errorf("IsDirectory(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func archived(t *testing.T, open func() (io.ReadCloser, error)) map[string]os.FileMode {
+	rc, err := open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]os.FileMode)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Uid != 0 || hdr.Gid != 0 || hdr.ModTime.Unix() != 0 {
+			t.Errorf("%s keeps its owner or modification time", hdr.Name)
+		}
+		got[hdr.Name] = hdr.FileInfo().Mode()
+	}
+}
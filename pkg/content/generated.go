@@ -0,0 +1,109 @@
+package content
+
+import (
+	"bytes"
+	"io"
+
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	gtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"github.com/rancherfederal/ocil/pkg/consts"
+	"github.com/rancherfederal/ocil/pkg/layer"
+)
+
+var _ artifacts.OCI = (*Generated)(nil)
+
+// Generated implements the OCI interface for a file generated in memory rather than read from a path, like manifests
+// rendered by hauler.  It's stored the same way as a local file, so it's extracted by its name.
+type Generated struct {
+	// Source is what the file was generated from, recorded in its config
+	Source string
+
+	name     string
+	data     []byte
+	computed bool
+	config   artifacts.Config
+	blob     gv1.Layer
+	manifest *gv1.Manifest
+}
+
+type generatedConfig struct {
+	Reference string `json:"reference"`
+}
+
+// NewGenerated returns the file named name holding data, generated from source
+func NewGenerated(source string, name string, data []byte) *Generated {
+	return &Generated{Source: source, name: name, data: data}
+}
+
+// Name is the name of the file
+func (g *Generated) Name() string {
+	return g.name
+}
+
+// Size is the size of the file's contents
+func (g *Generated) Size() int64 {
+	return int64(len(g.data))
+}
+
+func (g *Generated) MediaType() string {
+	return consts.OCIManifestSchema1
+}
+
+func (g *Generated) RawConfig() ([]byte, error) {
+	if err := g.compute(); err != nil {
+		return nil, err
+	}
+	return g.config.Raw()
+}
+
+func (g *Generated) Layers() ([]gv1.Layer, error) {
+	if err := g.compute(); err != nil {
+		return nil, err
+	}
+	return []gv1.Layer{g.blob}, nil
+}
+
+func (g *Generated) Manifest() (*gv1.Manifest, error) {
+	if err := g.compute(); err != nil {
+		return nil, err
+	}
+	return g.manifest, nil
+}
+
+func (g *Generated) compute() error {
+	if g.computed {
+		return nil
+	}
+
+	blob, err := layer.FromOpener(func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(g.data)), nil },
+		layer.WithMediaType(consts.FileLayerMediaType),
+		layer.WithAnnotations(map[string]string{ocispec.AnnotationTitle: g.name}))
+	if err != nil {
+		return err
+	}
+
+	layerDesc, err := partial.Descriptor(blob)
+	if err != nil {
+		return err
+	}
+
+	cfg := artifacts.ToConfig(&generatedConfig{Reference: g.Source}, artifacts.WithConfigMediaType(consts.FileLocalConfigMediaType))
+	cfgDesc, err := partial.Descriptor(cfg)
+	if err != nil {
+		return err
+	}
+
+	g.manifest = &gv1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     gtypes.MediaType(g.MediaType()),
+		Config:        *cfgDesc,
+		Layers:        []gv1.Descriptor{*layerDesc},
+	}
+	g.config = cfg
+	g.blob = blob
+	g.computed = true
+	return nil
+}
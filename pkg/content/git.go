@@ -0,0 +1,361 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	gtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancherfederal/ocil/pkg/artifacts"
+	"github.com/rancherfederal/ocil/pkg/consts"
+	"github.com/rancherfederal/ocil/pkg/layer"
+)
+
+const (
+	GitConfigMediaType = "application/vnd.content.hauler.git.config.v1+json"
+
+	// GitBundleLayerMediaType is the media type of a repository's layer, a git bundle of its selected refs
+	GitBundleLayerMediaType = "application/vnd.content.hauler.git.bundle.v1"
+
+	// GitHeadAnnotation records the ref a repository's clone checks out on its manifest
+	GitHeadAnnotation = "content.hauler.cattle.io/git-head"
+)
+
+var (
+	_ artifacts.OCI = (*Git)(nil)
+	_ io.Closer     = (*Git)(nil)
+)
+
+// Git implements the OCI interface for a git repository, stored as a git bundle of the repository's branches and tags.
+// The repository is cloned with the git executable, so any url (or local path) git understands can be stored.
+type Git struct {
+	URL  string
+	Refs []string
+
+	name     string
+	computed bool
+	bundle   string
+	config   artifacts.Config
+	blob     gv1.Layer
+	manifest *gv1.Manifest
+}
+
+type gitConfig struct {
+	URL string `json:"url"`
+
+	// Refs maps every stored ref to its commit
+	Refs map[string]string `json:"refs"`
+}
+
+// NewGit returns the repository at url restricted to refs (branch or tag names, or full ref names), or every branch and
+// tag when none are given.  The repository is named name, or otherwise after the last element of its url.
+func NewGit(url string, name string, refs []string) *Git {
+	if name == "" {
+		name = GitName(url)
+	}
+	return &Git{URL: url, Refs: refs, name: name}
+}
+
+// GitName returns the name of a repository from its url, without any .git suffix
+func GitName(source string) string {
+	p := source
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" {
+		p = u.Path
+	}
+	if i := strings.LastIndex(p, ":"); i >= 0 {
+		p = p[i+1:]
+	}
+	return strings.TrimSuffix(path.Base(filepath.ToSlash(strings.TrimRight(p, "/"))), ".git")
+}
+
+// Name is the name of the repository's reference
+func (g *Git) Name() string {
+	return g.name
+}
+
+func (g *Git) MediaType() string {
+	return consts.OCIManifestSchema1
+}
+
+func (g *Git) RawConfig() ([]byte, error) {
+	if err := g.compute(); err != nil {
+		return nil, err
+	}
+	return g.config.Raw()
+}
+
+func (g *Git) Layers() ([]gv1.Layer, error) {
+	if err := g.compute(); err != nil {
+		return nil, err
+	}
+	return []gv1.Layer{g.blob}, nil
+}
+
+func (g *Git) Manifest() (*gv1.Manifest, error) {
+	if err := g.compute(); err != nil {
+		return nil, err
+	}
+	return g.manifest, nil
+}
+
+// Close removes the repository's bundle once it's been stored, its layer can't be read after
+func (g *Git) Close() error {
+	if g.bundle == "" {
+		return nil
+	}
+	if err := os.Remove(g.bundle); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	g.bundle = ""
+	return nil
+}
+
+// compute mirrors the repository and bundles its selected refs.  The bundle is written to a temporary file rather than
+// held in memory, since its layer is read once for its digest and again to be stored, and Close removes it.
+func (g *Git) compute() error {
+	if g.computed {
+		return nil
+	}
+	ctx := context.TODO()
+
+	tmpdir, err := os.MkdirTemp("", "hauler")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	mirror := filepath.Join(tmpdir, "repo.git")
+	if _, err := runGit(ctx, "", "clone", "--mirror", "--quiet", "--", g.URL, mirror); err != nil {
+		return fmt.Errorf("clone %s: %w", g.URL, err)
+	}
+
+	refs, err := g.selectRefs(ctx, mirror)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bundle, err := os.CreateTemp("", "hauler-*.bundle")
+	if err != nil {
+		return err
+	}
+	bundle.Close()
+	g.bundle = bundle.Name()
+
+	args := append([]string{"bundle", "create", "--quiet", bundle.Name()}, names...)
+	if _, err := runGit(ctx, mirror, args...); err != nil {
+		g.Close()
+		return fmt.Errorf("bundle %s: %w", g.URL, err)
+	}
+
+	blob, err := layer.FromOpener(func() (io.ReadCloser, error) { return os.Open(bundle.Name()) },
+		layer.WithMediaType(GitBundleLayerMediaType),
+		layer.WithAnnotations(map[string]string{ocispec.AnnotationTitle: g.name + ".bundle"}))
+	if err != nil {
+		return err
+	}
+
+	layerDesc, err := partial.Descriptor(blob)
+	if err != nil {
+		return err
+	}
+
+	cfg := artifacts.ToConfig(&gitConfig{URL: g.URL, Refs: refs}, artifacts.WithConfigMediaType(GitConfigMediaType))
+	cfgDesc, err := partial.Descriptor(cfg)
+	if err != nil {
+		return err
+	}
+
+	var annotations map[string]string
+	if head := g.head(ctx, mirror, names); head != "" {
+		annotations = map[string]string{GitHeadAnnotation: head}
+	}
+
+	g.manifest = &gv1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     gtypes.MediaType(g.MediaType()),
+		Config:        *cfgDesc,
+		Layers:        []gv1.Descriptor{*layerDesc},
+		Annotations:   annotations,
+	}
+	g.config = cfg
+	g.blob = blob
+	g.computed = true
+	return nil
+}
+
+// selectRefs returns the full names and commits of the selected branches and tags of a repository, each selected ref
+// must exist
+func (g *Git) selectRefs(ctx context.Context, repo string) (map[string]string, error) {
+	out, err := runGit(ctx, repo, "for-each-ref", "--format=%(objectname) %(refname)", "refs/heads", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	return g.selected(parseRefs(out))
+}
+
+// selected returns the selected refs of every branch and tag of a repository, or all of them when none are selected
+func (g *Git) selected(all map[string]string) (map[string]string, error) {
+	if len(all) == 0 {
+		return nil, fmt.Errorf("repository %s has no branches or tags", g.URL)
+	}
+	if len(g.Refs) == 0 {
+		return all, nil
+	}
+
+	selected := make(map[string]string)
+	for _, ref := range g.Refs {
+		found := false
+		for _, name := range []string{ref, "refs/heads/" + ref, "refs/tags/" + ref} {
+			if commit, ok := all[name]; ok {
+				selected[name] = commit
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("repository %s has no branch or tag %s", g.URL, ref)
+		}
+	}
+	return selected, nil
+}
+
+// parseRefs parses refs listed as an object name and a ref name per line, skipping the peeled objects of tags
+func parseRefs(out string) map[string]string {
+	refs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && !strings.HasSuffix(fields[1], "^{}") {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	return refs
+}
+
+// Key identifies the repository's selected refs without cloning it: its url and the commit of every selected ref, as
+// recorded by its config.  Repositories with the same key store the same refs.
+func (g *Git) Key() (string, error) {
+	out, err := runGit(context.TODO(), "", "ls-remote", "--heads", "--tags", "--", g.URL)
+	if err != nil {
+		return "", fmt.Errorf("list refs of %s: %w", g.URL, err)
+	}
+
+	refs, err := g.selected(parseRefs(out))
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(gitConfig{URL: g.URL, Refs: refs})
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(data).String(), nil
+}
+
+// head returns the ref a clone checks out: the repository's own default branch when it's selected, otherwise the first
+// selected branch or tag
+func (g *Git) head(ctx context.Context, repo string, refs []string) string {
+	if len(refs) == 0 {
+		return ""
+	}
+
+	if out, err := runGit(ctx, repo, "symbolic-ref", "--quiet", "HEAD"); err == nil {
+		head := strings.TrimSpace(out)
+		for _, ref := range refs {
+			if ref == head {
+				return head
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			return ref
+		}
+	}
+	return refs[0]
+}
+
+// RestoreGit restores a repository from a git bundle into dir as a bare repository, or a working clone checking out
+// head.  Every branch and tag of the bundle is restored as a local branch or tag.
+func RestoreGit(ctx context.Context, bundle string, dir string, head string, bare bool) error {
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("restore repository: %s already exists", dir)
+	}
+
+	bundle, err := filepath.Abs(bundle)
+	if err != nil {
+		return err
+	}
+
+	initArgs := []string{"init", "--quiet"}
+	if bare {
+		initArgs = append(initArgs, "--bare")
+	}
+	if _, err := runGit(ctx, "", append(initArgs, "--", dir)...); err != nil {
+		return err
+	}
+
+	if _, err := runGit(ctx, dir, "fetch", "--quiet", "--update-head-ok", bundle, "refs/*:refs/*"); err != nil {
+		return fmt.Errorf("restore repository from %s: %w", bundle, err)
+	}
+
+	if head == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(head, "refs/heads/") {
+		if _, err := runGit(ctx, dir, "symbolic-ref", "HEAD", head); err != nil {
+			return err
+		}
+		if !bare {
+			_, err = runGit(ctx, dir, "reset", "--quiet", "--hard")
+		}
+		return err
+	}
+
+	// Other refs, like tags, are checked out detached
+	if bare {
+		_, err = runGit(ctx, dir, "update-ref", "--no-deref", "HEAD", head+"^{commit}")
+		return err
+	}
+	_, err = runGit(ctx, dir, "checkout", "--quiet", "--detach", head)
+	return err
+}
+
+// runGit runs git in dir, returning its output or an error holding what it reported
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
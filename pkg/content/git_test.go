@@ -0,0 +1,195 @@
+package content_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "app")
+	git(t, "", "init", "--quiet", "--initial-branch=main", src)
+	if err := os.WriteFile(filepath.Join(src, "run.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	git(t, src, "add", "run.sh")
+	git(t, src, "commit", "--quiet", "-m", "initial")
+	git(t, src, "tag", "v1.0.0")
+	git(t, src, "checkout", "--quiet", "-b", "dev")
+	git(t, src, "commit", "--quiet", "--allow-empty", "-m", "dev")
+	git(t, src, "checkout", "--quiet", "main")
+
+	tests := []struct {
+		name     string
+		refs     []string
+		bare     bool
+		wantHead string
+		wantRefs []string
+		wantErr  bool
+	}{
+		{
+			name:     "should restore every branch and tag in a working clone",
+			wantHead: "refs/heads/main",
+			wantRefs: []string{"refs/heads/dev", "refs/heads/main", "refs/tags/v1.0.0"},
+		},
+		{
+			name:     "should restore selected refs in a bare repository",
+			refs:     []string{"dev", "refs/tags/v1.0.0"},
+			bare:     true,
+			wantHead: "refs/heads/dev",
+			wantRefs: []string{"refs/heads/dev", "refs/tags/v1.0.0"},
+		},
+		{
+			name:    "should fail on a missing ref",
+			refs:    []string{"v2.0.0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+
+			g := content.NewGit("file://"+src, "", tt.refs)
+			defer func() {
+				if err := g.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if left, _ := os.ReadDir(tmp); len(left) != 0 {
+					t.Errorf("temporary files left after Close: %v", left)
+				}
+			}()
+			if g.Name() != "app" {
+				t.Errorf("Name() = %s, want app", g.Name())
+			}
+
+			m, err := g.Manifest()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Manifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			head := m.Annotations[content.GitHeadAnnotation]
+			if head != tt.wantHead {
+				t.Errorf("head = %s, want %s", head, tt.wantHead)
+			}
+
+			layers, err := g.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := layers[0].Compressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+
+			dir := t.TempDir()
+			bundle := filepath.Join(dir, "app.bundle")
+			f, err := os.Create(bundle)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.ReadFrom(rc); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			repo := filepath.Join(dir, "app")
+			if err := content.RestoreGit(ctx, bundle, repo, head, tt.bare); err != nil {
+				t.Fatal(err)
+			}
+
+			refs := strings.Fields(git(t, repo, "for-each-ref", "--format=%(refname)"))
+			if strings.Join(refs, " ") != strings.Join(tt.wantRefs, " ") {
+				t.Errorf("restored refs %v, want %v", refs, tt.wantRefs)
+			}
+			if got := strings.TrimSpace(git(t, repo, "symbolic-ref", "HEAD")); got != tt.wantHead {
+				t.Errorf("restored HEAD %s, want %s", got, tt.wantHead)
+			}
+
+			if !tt.bare {
+				fi, err := os.Stat(filepath.Join(repo, "run.sh"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm() != 0755 {
+					t.Errorf("run.sh mode = %s, want 0755", fi.Mode())
+				}
+				if status := git(t, repo, "status", "--porcelain"); status != "" {
+					t.Errorf("restored clone isn't clean: %s", status)
+				}
+			}
+		})
+	}
+}
+
+func TestGitKey(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	src := filepath.Join(t.TempDir(), "app")
+	git(t, "", "init", "--quiet", "--initial-branch=main", src)
+	git(t, src, "commit", "--quiet", "--allow-empty", "-m", "initial")
+	git(t, src, "tag", "--annotate", "-m", "release", "v1.0.0")
+
+	g := content.NewGit("file://"+src, "", nil)
+	defer g.Close()
+
+	key, err := g.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key identifies the config the cloned repository is stored with, without cloning it
+	cfg, err := g.RawConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := digest.FromBytes(cfg).String(); key != want {
+		t.Errorf("Key() = %s, want the config digest %s", key, want)
+	}
+
+	git(t, src, "commit", "--quiet", "--allow-empty", "-m", "next")
+	next, err := content.NewGit("file://"+src, "", nil).Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == key {
+		t.Errorf("Key() = %s after a new commit, want a different key", next)
+	}
+
+	if _, err := content.NewGit("file://"+src, "", []string{"v2.0.0"}).Key(); err == nil {
+		t.Error("Key() of a missing ref succeeded, want an error")
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=hauler", "GIT_AUTHOR_EMAIL=hauler@example.com",
+		"GIT_COMMITTER_NAME=hauler", "GIT_COMMITTER_EMAIL=hauler@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
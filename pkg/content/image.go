@@ -0,0 +1,177 @@
+package content
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rancherfederal/ocil/pkg/artifacts/image"
+)
+
+// NewImage returns a remote image like image.NewImage, except the given options take precedence over the default
+// keychain so that images can be pulled with other credentials
+func NewImage(ref string, opts ...remote.Option) (*image.Image, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := remote.Image(r, remoteOptions(opts)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &image.Image{
+		Name:  ref,
+		Image: img,
+	}, nil
+}
+
+// PlatformImage is a multi-platform image restricted to a set of platforms
+type PlatformImage struct {
+	// Name is the reference the image was pulled from
+	Name string
+
+	// Source is the digest of the complete index the platforms were selected from
+	Source gv1.Hash
+
+	Platforms []gv1.Platform
+
+	// Index holds only the manifests of the selected platforms
+	Index gv1.ImageIndex
+}
+
+// NewPlatformImage returns the image at ref restricted to platforms.  A multi-platform image is returned as a
+// PlatformImage holding only the matching platforms, while a single platform image is returned as an image when it
+// matches.  Either way it's an error for none of the platforms to match.
+func NewPlatformImage(ref string, platforms []gv1.Platform, opts ...remote.Option) (*image.Image, *PlatformImage, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desc, err := remote.Get(r, remoteOptions(opts)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !desc.MediaType.IsIndex() {
+		img, err := desc.Image()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		p, err := configPlatform(img)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !MatchesPlatform(p, platforms) {
+			return nil, nil, fmt.Errorf("image %s is only available for %s", ref, FormatPlatform(p))
+		}
+		return &image.Image{Name: ref, Image: img}, nil, nil
+	}
+
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filtered := mutate.RemoveManifests(idx, func(d gv1.Descriptor) bool {
+		return d.Platform == nil || !MatchesPlatform(*d.Platform, platforms)
+	})
+
+	im, err := filtered.IndexManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(im.Manifests) == 0 {
+		return nil, nil, fmt.Errorf("image %s is not available for any of the platforms %s", ref, formatPlatforms(platforms))
+	}
+
+	return nil, &PlatformImage{
+		Name:      ref,
+		Source:    desc.Digest,
+		Platforms: platforms,
+		Index:     filtered,
+	}, nil
+}
+
+// configPlatform returns the platform an image's config declares, including the variant go-containerregistry's config
+// file doesn't decode
+func configPlatform(img gv1.Image) (gv1.Platform, error) {
+	raw, err := img.RawConfigFile()
+	if err != nil {
+		return gv1.Platform{}, err
+	}
+
+	var p gv1.Platform
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return gv1.Platform{}, err
+	}
+	return gv1.Platform{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant}, nil
+}
+
+// ParsePlatform parses a platform given as os/arch[/variant]
+func ParsePlatform(s string) (gv1.Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return gv1.Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+	}
+
+	p := gv1.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// ParsePlatforms parses every platform given as os/arch[/variant]
+func ParsePlatforms(ss []string) ([]gv1.Platform, error) {
+	var platforms []gv1.Platform
+	for _, s := range ss {
+		p, err := ParsePlatform(s)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+// FormatPlatform formats a platform as os/arch[/variant]
+func FormatPlatform(p gv1.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// MatchesPlatform reports whether a platform matches any of platforms, a platform without a variant matches every
+// variant of its architecture
+func MatchesPlatform(p gv1.Platform, platforms []gv1.Platform) bool {
+	for _, want := range platforms {
+		if p.OS == want.OS && p.Architecture == want.Architecture && (want.Variant == "" || p.Variant == want.Variant) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPlatforms(platforms []gv1.Platform) string {
+	ss := make([]string, len(platforms))
+	for i, p := range platforms {
+		ss[i] = FormatPlatform(p)
+	}
+	return strings.Join(ss, ", ")
+}
+
+// remoteOptions prepends the default keychain to opts, letting any keychain in opts take precedence
+func remoteOptions(opts []remote.Option) []remote.Option {
+	return append([]remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain)}, opts...)
+}
@@ -0,0 +1,244 @@
+package content_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+func TestNewPlatformImage(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	var adds []mutate.IndexAddendum
+	for _, p := range []gv1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+	} {
+		p := p
+		adds = append(adds, mutate.IndexAddendum{Add: newImage(t, p), Descriptor: gv1.Descriptor{Platform: &p}})
+	}
+	push(t, host+"/library/multi:v1", mutate.AppendManifests(empty.Index, adds...))
+	push(t, host+"/library/single:v1", newImage(t, gv1.Platform{OS: "linux", Architecture: "amd64"}))
+	push(t, host+"/library/single-arm:v1", newImage(t, gv1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}))
+
+	tests := []struct {
+		name      string
+		ref       string
+		platforms []string
+		want      []string
+		wantImage bool
+		wantErr   bool
+	}{
+		{
+			name:      "multi-platform image",
+			ref:       host + "/library/multi:v1",
+			platforms: []string{"linux/arm64", "linux/arm/v7"},
+			want:      []string{"linux/arm64/v8", "linux/arm/v7"},
+		},
+		{
+			name:      "multi-platform image without a matching platform",
+			ref:       host + "/library/multi:v1",
+			platforms: []string{"windows/amd64"},
+			wantErr:   true,
+		},
+		{
+			name:      "single platform image",
+			ref:       host + "/library/single:v1",
+			platforms: []string{"linux/amd64"},
+			wantImage: true,
+		},
+		{
+			name:      "single platform image of another platform",
+			ref:       host + "/library/single:v1",
+			platforms: []string{"linux/arm64"},
+			wantErr:   true,
+		},
+		{
+			name:      "single platform image with a variant",
+			ref:       host + "/library/single-arm:v1",
+			platforms: []string{"linux/arm/v7"},
+			wantImage: true,
+		},
+		{
+			name:      "single platform image of another variant",
+			ref:       host + "/library/single-arm:v1",
+			platforms: []string{"linux/arm/v6"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			platforms, err := content.ParsePlatforms(tt.platforms)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, pimg, err := content.NewPlatformImage(tt.ref, platforms)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPlatformImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if tt.wantImage {
+				if img == nil || pimg != nil {
+					t.Fatalf("NewPlatformImage() = %v, %v, want an image", img, pimg)
+				}
+				return
+			}
+
+			im, err := pimg.Index.IndexManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, desc := range im.Manifests {
+				got = append(got, content.FormatPlatform(*desc.Platform))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewPlatformImage() platforms = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     gv1.Platform
+		wantErr  bool
+	}{
+		{platform: "linux/amd64", want: gv1.Platform{OS: "linux", Architecture: "amd64"}},
+		{platform: "linux/arm/v7", want: gv1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{platform: "linux", wantErr: true},
+		{platform: "linux//v7", wantErr: true},
+		{platform: "linux/arm/v7/extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			got, err := content.ParsePlatform(tt.platform)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePlatform() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newImage(t *testing.T, p gv1.Platform) gv1.Image {
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.OS = p.OS
+	cfg.Architecture = p.Architecture
+
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Variant == "" {
+		return img
+	}
+
+	raw, err := img.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		t.Fatal(err)
+	}
+	config["variant"] = p.Variant
+	raw, err = json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = m.DeepCopy()
+	m.Config.Digest, m.Config.Size, err = gv1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &variantImage{Image: img, config: raw, manifest: m}
+}
+
+// variantImage is an image whose config declares a variant, which go-containerregistry's config file doesn't encode
+type variantImage struct {
+	gv1.Image
+	config   []byte
+	manifest *gv1.Manifest
+}
+
+func (i *variantImage) RawConfigFile() ([]byte, error) {
+	return i.config, nil
+}
+
+func (i *variantImage) ConfigName() (gv1.Hash, error) {
+	return i.manifest.Config.Digest, nil
+}
+
+func (i *variantImage) Manifest() (*gv1.Manifest, error) {
+	return i.manifest, nil
+}
+
+func (i *variantImage) RawManifest() ([]byte, error) {
+	return json.Marshal(i.manifest)
+}
+
+func (i *variantImage) Digest() (gv1.Hash, error) {
+	return partial.Digest(i)
+}
+
+func (i *variantImage) Size() (int64, error) {
+	return partial.Size(i)
+}
+
+func push(t *testing.T, ref string, artifact interface{}) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch a := artifact.(type) {
+	case gv1.ImageIndex:
+		err = remote.WriteIndex(r, a)
+	case gv1.Image:
+		err = remote.Write(r, a)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
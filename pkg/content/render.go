@@ -0,0 +1,139 @@
+package content
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Variables are the values substituted into content documents by Render, keyed by name
+type Variables map[string]string
+
+// ParseVariables parses variables given as key=value pairs
+func ParseVariables(pairs []string) (Variables, error) {
+	vars := make(Variables)
+	for _, p := range pairs {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected key=value", p)
+		}
+		vars[kv[0]] = kv[1]
+	}
+	return vars, nil
+}
+
+// LoadVariables loads variables from a yaml file, nested keys are joined with dots so that
+//
+//	k3s:
+//	  version: v1.22.2+k3s2
+//
+// defines the variable k3s.version
+func LoadVariables(path string) (Variables, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parse values file %s: %w", path, err)
+	}
+
+	vars := make(Variables)
+	flatten(vars, "", values)
+	return vars, nil
+}
+
+func flatten(vars Variables, prefix string, v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			flatten(vars, joinPath(prefix, k), e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			flatten(vars, joinPath(prefix, strconv.Itoa(i)), e)
+		}
+	case float64:
+		vars[prefix] = strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		vars[prefix] = ""
+	default:
+		vars[prefix] = fmt.Sprint(t)
+	}
+}
+
+// Merge returns the variables of every set combined, later sets take precedence
+func Merge(sets ...Variables) Variables {
+	vars := make(Variables)
+	for _, s := range sets {
+		for k, v := range s {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// variablePattern matches $${escaped}, ${name} and ${name:-default}
+var variablePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_.-]*)(:-[^}]*)?\}`)
+
+// Render substitutes every ${name} in a document with its variable, falling back to the environment and then to the
+// default given by ${name:-default}.  $${name} is left as the literal ${name}, and full line comments are not rendered.
+// Referencing an undefined variable without a default is an error.
+func Render(data []byte, vars Variables) ([]byte, error) {
+	undefined := make(map[string][]int)
+
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		lines[i] = variablePattern.ReplaceAllStringFunc(line, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+
+			sub := variablePattern.FindStringSubmatch(match)
+			name := sub[1]
+
+			if v, ok := vars[name]; ok {
+				return v
+			}
+			if v, ok := os.LookupEnv(name); ok {
+				return v
+			}
+			if sub[2] != "" {
+				return strings.TrimPrefix(sub[2], ":-")
+			}
+
+			undefined[name] = append(undefined[name], i+1)
+			return match
+		})
+	}
+
+	if len(undefined) > 0 {
+		names := make([]string, 0, len(undefined))
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		msgs := make([]string, len(names))
+		for i, name := range names {
+			lns := make([]string, len(undefined[name]))
+			for j, ln := range undefined[name] {
+				lns[j] = strconv.Itoa(ln)
+			}
+			msgs[i] = fmt.Sprintf("${%s} (line %s)", name, strings.Join(lns, ", "))
+		}
+		return nil, fmt.Errorf("undefined variables: %s", strings.Join(msgs, ", "))
+	}
+
+	return []byte(strings.Join(lines, "")), nil
+}
@@ -0,0 +1,85 @@
+package content_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+func TestRender(t *testing.T) {
+	t.Setenv("HAULER_TEST_MIRROR", "mirror.example.com")
+
+	tests := []struct {
+		name    string
+		doc     string
+		vars    content.Variables
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "variables",
+			doc:  "version: ${k3s.version}\n",
+			vars: content.Variables{"k3s.version": "v1.22.2+k3s2"},
+			want: "version: v1.22.2+k3s2\n",
+		},
+		{
+			name: "environment",
+			doc:  "name: ${HAULER_TEST_MIRROR}/library/busybox\n",
+			want: "name: mirror.example.com/library/busybox\n",
+		},
+		{
+			name: "variables take precedence over the environment",
+			doc:  "name: ${HAULER_TEST_MIRROR}/library/busybox\n",
+			vars: content.Variables{"HAULER_TEST_MIRROR": "docker.io"},
+			want: "name: docker.io/library/busybox\n",
+		},
+		{
+			name: "default",
+			doc:  "version: ${chart.version:-6.0.3}\n",
+			want: "version: 6.0.3\n",
+		},
+		{
+			name: "escaped and commented",
+			doc:  "# ${undefined}\nscript: echo $${HOME}\n",
+			want: "# ${undefined}\nscript: echo ${HOME}\n",
+		},
+		{
+			name:    "undefined",
+			doc:     "version: ${undefined}\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := content.Render([]byte(tt.doc), tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vars.yaml")
+	if err := os.WriteFile(path, []byte("k3s:\n  version: v1.22.2+k3s2\nreplicas: 1000000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := content.LoadVariables(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := content.Variables{"k3s.version": "v1.22.2+k3s2", "replicas": "1000000"}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("LoadVariables()[%q] = %q, want %q", k, vars[k], v)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package content
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Schema returns a JSON Schema matching any content or collection document, for use by editors and other tooling
+func Schema() ([]byte, error) {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	oneOf := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		k := kinds[name]
+
+		s := schemaOf(reflect.TypeOf(k.new()))
+		s["title"] = name
+		props := s["properties"].(map[string]interface{})
+		props["apiVersion"] = map[string]interface{}{"const": k.groupVersion.String()}
+		props["kind"] = map[string]interface{}{"const": name}
+		s["required"] = append([]string{"apiVersion", "kind"}, s["required"].([]string)...)
+
+		oneOf = append(oneOf, s)
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "hauler content",
+		"oneOf":   oneOf,
+	}, "", "  ")
+}
+
+func schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if isUnmarshaler(t) {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		required := []string{}
+		for _, f := range fieldsOf(t) {
+			props[f.name] = schemaOf(f.typ)
+			if f.required {
+				required = append(required, f.name)
+			}
+		}
+
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaOf(t.Elem()),
+		}
+
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaOf(t.Elem()),
+		}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+
+	return map[string]interface{}{}
+}
@@ -0,0 +1,97 @@
+package content
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+)
+
+// Tags lists the tags of an image repository and returns those selected by filter, newest first
+func Tags(repository string, filter v1alpha1.ImageTagFilter, opts ...remote.Option) ([]string, error) {
+	r, err := name.NewRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := remote.List(r, remoteOptions(opts)...)
+	if err != nil {
+		return nil, fmt.Errorf("list tags of %s: %w", repository, err)
+	}
+
+	selected, err := FilterTags(tags, filter)
+	if err != nil {
+		return nil, fmt.Errorf("image repository %s: %w", repository, err)
+	}
+	return selected, nil
+}
+
+// cosignTag matches the tags cosign stores an image's signatures, attestations and sboms under
+var cosignTag = regexp.MustCompile(`^sha256-[a-f0-9]+\.(sig|att|sbom)$`)
+
+// FilterTags returns the tags selected by filter, newest first.  Tags are ordered by semantic version, followed by the
+// tags that aren't versions in reverse lexical order.  Cosign's tags are skipped unless the filter selects signatures.
+// It's an error for no tag to be selected.
+func FilterTags(tags []string, filter v1alpha1.ImageTagFilter) ([]string, error) {
+	var constraint *semver.Constraints
+	if filter.Semver != "" {
+		c, err := semver.NewConstraint(filter.Semver)
+		if err != nil {
+			return nil, fmt.Errorf("invalid semver constraint %s: %w", filter.Semver, err)
+		}
+		constraint = c
+	}
+
+	var re *regexp.Regexp
+	if filter.Regex != "" {
+		r, err := regexp.Compile(filter.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag regex %s: %w", filter.Regex, err)
+		}
+		re = r
+	}
+
+	var versions []*semver.Version
+	var others []string
+	for _, t := range tags {
+		if !filter.Signatures && cosignTag.MatchString(t) {
+			continue
+		}
+		if re != nil && !re.MatchString(t) {
+			continue
+		}
+
+		v, err := semver.NewVersion(t)
+		if err != nil {
+			if constraint == nil {
+				others = append(others, t)
+			}
+			continue
+		}
+		if constraint == nil || constraint.Check(v) {
+			versions = append(versions, v)
+		}
+	}
+
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+	sort.Sort(sort.Reverse(sort.StringSlice(others)))
+
+	selected := make([]string, 0, len(versions)+len(others))
+	for _, v := range versions {
+		selected = append(selected, v.Original())
+	}
+	selected = append(selected, others...)
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no tag matches the filter")
+	}
+	if filter.Newest > 0 && len(selected) > filter.Newest {
+		selected = selected[:filter.Newest]
+	}
+	return selected, nil
+}
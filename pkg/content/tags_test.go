@@ -0,0 +1,85 @@
+package content_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+func TestTags(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	repo := strings.TrimPrefix(srv.URL, "http://") + "/rancher/cowsay"
+
+	for _, tag := range []string{"v1.9.3", "v1.10.0", "v1.10.1", "v1.10.2-rc1", "v2.0.0", "latest", "edge", "sha256-abc.sig", "sha256-abc.att"} {
+		ref, err := name.ParseReference(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, empty.Image); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filter  v1alpha1.ImageTagFilter
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "should select every tag, newest first",
+			want: []string{"v2.0.0", "v1.10.2-rc1", "v1.10.1", "v1.10.0", "v1.9.3", "latest", "edge"},
+		},
+		{
+			name:   "should select cosign's tags when selecting signatures",
+			filter: v1alpha1.ImageTagFilter{Regex: `^sha256-`, Signatures: true},
+			want:   []string{"sha256-abc.sig", "sha256-abc.att"},
+		},
+		{
+			name:   "should select versions matching a semver constraint",
+			filter: v1alpha1.ImageTagFilter{Semver: ">=1.10.0 <2.0.0"},
+			want:   []string{"v1.10.1", "v1.10.0"},
+		},
+		{
+			name:   "should select tags matching a regex",
+			filter: v1alpha1.ImageTagFilter{Regex: `^(latest|edge)$`},
+			want:   []string{"latest", "edge"},
+		},
+		{
+			name:   "should combine filters and keep only the newest",
+			filter: v1alpha1.ImageTagFilter{Semver: ">=1.0.0-0", Regex: `^v1\.`, Newest: 2},
+			want:   []string{"v1.10.2-rc1", "v1.10.1"},
+		},
+		{
+			name:    "should fail when no tag matches",
+			filter:  v1alpha1.ImageTagFilter{Semver: ">=3.0.0"},
+			wantErr: true,
+		},
+		{
+			name:    "should fail on an invalid regex",
+			filter:  v1alpha1.ImageTagFilter{Regex: "("},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := content.Tags(repo, tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Tags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
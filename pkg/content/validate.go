@@ -0,0 +1,267 @@
+package content
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// FieldError is a problem with a single field of a document
+type FieldError struct {
+	// Field is the path to the field, such as spec.charts[0].repoURL
+	Field  string
+	Detail string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Detail)
+}
+
+// ValidationError holds every problem found in a document
+type ValidationError []*FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate strictly decodes a document against the type of its kind, reporting every unknown field, missing required
+// field, and value of the wrong type as a ValidationError.  Empty documents are valid.
+func Validate(data []byte) error {
+	jdata, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(jdata, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		return nil
+	}
+
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return ValidationError{{Field: "(root)", Detail: "expected an object, got " + jsonType(raw)}}
+	}
+
+	kindName, _ := obj["kind"].(string)
+	if kindName == "" {
+		return ValidationError{{Field: "kind", Detail: "required field is missing"}}
+	}
+
+	k, ok := kinds[kindName]
+	if !ok {
+		return ValidationError{{Field: "kind", Detail: fmt.Sprintf("unrecognized content/collection kind %q", kindName)}}
+	}
+
+	var errs ValidationError
+	if apiVersion, _ := obj["apiVersion"].(string); apiVersion != k.groupVersion.String() {
+		errs = append(errs, &FieldError{
+			Field:  "apiVersion",
+			Detail: fmt.Sprintf("%q is not valid for kind %s, expected %q", apiVersion, kindName, k.groupVersion.String()),
+		})
+	}
+
+	errs = append(errs, validateValue("", raw, reflect.TypeOf(k.new()))...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateValue(path string, v interface{}, t reflect.Type) []*FieldError {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Types with their own decoding (timestamps, quantities, etc...) are trusted to validate themselves
+	if v == nil || isUnmarshaler(t) {
+		return nil
+	}
+
+	mismatch := func(want string) []*FieldError {
+		return []*FieldError{{Field: fieldPath(path), Detail: fmt.Sprintf("expected %s, got %s", want, jsonType(v))}}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch("object")
+		}
+
+		fields := make(map[string]field)
+		for _, f := range fieldsOf(t) {
+			fields[f.name] = f
+		}
+
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var errs []*FieldError
+		for _, k := range keys {
+			f, ok := fields[k]
+			if !ok {
+				errs = append(errs, &FieldError{Field: joinPath(path, k), Detail: "unknown field"})
+				continue
+			}
+			errs = append(errs, validateValue(joinPath(path, k), obj[k], f.typ)...)
+		}
+
+		for _, f := range fieldsOf(t) {
+			if _, ok := obj[f.name]; f.required && !ok {
+				errs = append(errs, &FieldError{Field: joinPath(path, f.name), Detail: "required field is missing"})
+			}
+		}
+		return errs
+
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return mismatch("array")
+		}
+
+		var errs []*FieldError
+		for i, e := range arr {
+			errs = append(errs, validateValue(fmt.Sprintf("%s[%d]", path, i), e, t.Elem())...)
+		}
+		return errs
+
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch("object")
+		}
+
+		var errs []*FieldError
+		for k, e := range obj {
+			errs = append(errs, validateValue(joinPath(path, k), e, t.Elem())...)
+		}
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		return errs
+
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			return mismatch("string")
+		}
+
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			return mismatch("boolean")
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return mismatch("integer")
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if _, ok := v.(float64); !ok {
+			return mismatch("number")
+		}
+	}
+
+	return nil
+}
+
+// field is a single json field of a struct
+type field struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+// fieldsOf returns the json fields of a struct, including those of inlined structs
+func fieldsOf(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, fieldsOf(ft)...)
+				continue
+			}
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		required := tag != ""
+		for _, o := range opts[1:] {
+			if o == "omitempty" {
+				required = false
+			}
+		}
+
+		fields = append(fields, field{name: name, typ: sf.Type, required: required})
+	}
+	return fields
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func isUnmarshaler(t reflect.Type) bool {
+	return t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType)
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
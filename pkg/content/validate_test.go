@@ -0,0 +1,122 @@
+package content_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "valid document",
+			doc: `
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Charts
+metadata:
+  name: charts
+spec:
+  charts:
+    - name: podinfo
+      repoURL: https://stefanprodan.github.io/podinfo
+      version: 6.0.3
+`,
+		},
+		{
+			name: "empty document",
+			doc:  "# nothing here",
+		},
+		{
+			name: "unknown and mistyped fields",
+			doc: `
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Charts
+spec:
+  charts:
+    - name: podinfo
+      repoUrl: https://stefanprodan.github.io/podinfo
+      version: 6
+`,
+			want: []string{
+				"spec.charts[0].repoUrl: unknown field",
+				"spec.charts[0].version: expected string, got number",
+			},
+		},
+		{
+			name: "missing required field",
+			doc: `
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Files
+spec:
+  files:
+    - name: script.sh
+`,
+			want: []string{
+				"spec.files[0].path: required field is missing",
+			},
+		},
+		{
+			name: "inlined fields",
+			doc: `
+apiVersion: collection.hauler.cattle.io/v1alpha1
+kind: ThickCharts
+spec:
+  charts:
+    - name: podinfo
+      extraImages:
+        - reference: busybox
+`,
+			want: []string{
+				"spec.charts[0].extraImages[0].reference: unknown field",
+				"spec.charts[0].extraImages[0].ref: required field is missing",
+			},
+		},
+		{
+			name: "mismatched group",
+			doc: `
+apiVersion: collection.hauler.cattle.io/v1alpha1
+kind: Images
+`,
+			want: []string{
+				`apiVersion: "collection.hauler.cattle.io/v1alpha1" is not valid for kind Images, expected "content.hauler.cattle.io/v1alpha1"`,
+			},
+		},
+		{
+			name: "unknown kind",
+			doc: `
+apiVersion: content.hauler.cattle.io/v1alpha1
+kind: Image
+`,
+			want: []string{
+				`kind: unrecognized content/collection kind "Image"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := content.Validate([]byte(tt.doc))
+
+			var got []string
+			if err != nil {
+				var verr content.ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("Validate() error = %v, want a ValidationError", err)
+				}
+				for _, fe := range verr {
+					got = append(got, fe.Error())
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
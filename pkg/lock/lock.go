@@ -0,0 +1,451 @@
+// Package lock provides a lock file pinning every item resolved during a sync, allowing subsequent syncs to reproduce
+// the exact same store contents.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	syaml "sigs.k8s.io/yaml"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+)
+
+const DefaultFilename = "hauler.lock"
+
+var (
+	ErrNotPinned = errors.New("not pinned in lock")
+	ErrMismatch  = errors.New("does not match lock")
+)
+
+// Lock pins every resolved image digest, the tags selected from image repositories, chart version and digest, file digest and k3s and rke2 version.  When locked, a Lock
+// only verifies items against its pins and refuses anything that is not pinned or does not match.  A Lock is safe for
+// concurrent use.
+type Lock struct {
+	Images            []Image           `json:"images,omitempty"`
+	ImageRepositories []ImageRepository `json:"imageRepositories,omitempty"`
+	Charts            []Chart           `json:"charts,omitempty"`
+	Files             []File            `json:"files,omitempty"`
+	K3s               []K3s             `json:"k3s,omitempty"`
+	RKE2              []RKE2            `json:"rke2,omitempty"`
+
+	locked bool
+	mu     sync.Mutex
+}
+
+type Image struct {
+	// Name is the image reference as declared
+	Name string `json:"name"`
+
+	// Digest is the manifest digest the name resolved to
+	Digest string `json:"digest"`
+}
+
+type ImageRepository struct {
+	// Name is the repository as declared
+	Name string `json:"name"`
+
+	// Tags are the tags selected from the repository, each image's digest is pinned with the images
+	Tags []string `json:"tags"`
+}
+
+type Chart struct {
+	Name    string `json:"name"`
+	RepoURL string `json:"repoURL,omitempty"`
+
+	// Constraint is the version constraint as declared
+	Constraint string `json:"constraint,omitempty"`
+
+	// Range is set for each of the versions selected by a range of versions, rather than the single newest version
+	Range bool `json:"range,omitempty"`
+
+	// Version is the exact chart version the constraint resolved to
+	Version string `json:"version"`
+
+	// Digest is the digest of the chart tarball
+	Digest string `json:"digest"`
+}
+
+type File struct {
+	// Path is the file's path as declared, can be a local or remote path
+	Path string `json:"path"`
+
+	// Digest is the sha256 digest of the file contents
+	Digest string `json:"digest"`
+}
+
+type K3s struct {
+	// Version is the version or channel as declared
+	Version string `json:"version"`
+
+	// Resolved is the exact k3s release the version resolved to
+	Resolved string `json:"resolved"`
+}
+
+type RKE2 struct {
+	// Version is the version or channel as declared
+	Version string `json:"version"`
+
+	// Resolved is the exact rke2 release the version resolved to
+	Resolved string `json:"resolved"`
+}
+
+// New returns an empty Lock that records every pin
+func New() *Lock {
+	return &Lock{}
+}
+
+// Load reads a Lock from disk, the returned Lock is locked
+func Load(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{}
+	if err := yaml.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("parse lock %s: %w", path, err)
+	}
+	l.locked = true
+	return l, nil
+}
+
+// Save writes the Lock to disk with its entries sorted
+func (l *Lock) Save(path string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sort.Slice(l.Images, func(i, j int) bool { return l.Images[i].Name < l.Images[j].Name })
+	sort.Slice(l.ImageRepositories, func(i, j int) bool { return l.ImageRepositories[i].Name < l.ImageRepositories[j].Name })
+	sort.Slice(l.Charts, func(i, j int) bool {
+		a, b := l.Charts[i], l.Charts[j]
+		if a.RepoURL != b.RepoURL {
+			return a.RepoURL < b.RepoURL
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Constraint < b.Constraint
+	})
+	sort.Slice(l.Files, func(i, j int) bool { return l.Files[i].Path < l.Files[j].Path })
+	sort.Slice(l.K3s, func(i, j int) bool { return l.K3s[i].Version < l.K3s[j].Version })
+	sort.Slice(l.RKE2, func(i, j int) bool { return l.RKE2[i].Version < l.RKE2[j].Version })
+
+	data, err := syaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Locked returns whether the Lock enforces its pins instead of recording them
+func (l *Lock) Locked() bool {
+	return l != nil && l.locked
+}
+
+// ImageDigest returns the digest an image is pinned to, if any
+func (l *Lock) ImageDigest(name string) (string, error) {
+	if !l.Locked() {
+		return "", nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, i := range l.Images {
+		if i.Name == name {
+			return i.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("image %s: %w", name, ErrNotPinned)
+}
+
+// ImageTags returns the tags selected from an image repository pinned in the lock when locked, or nil otherwise
+func (l *Lock) ImageTags(repository string) ([]string, error) {
+	if !l.Locked() {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, r := range l.ImageRepositories {
+		if r.Name == repository {
+			return append([]string{}, r.Tags...), nil
+		}
+	}
+	return nil, fmt.Errorf("image repository %s: %w", repository, ErrNotPinned)
+}
+
+// PinImageTags records the tags selected from an image repository, or verifies they're pinned when locked
+func (l *Lock) PinImageTags(repository string, tags []string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for idx, r := range l.ImageRepositories {
+		if r.Name != repository {
+			continue
+		}
+
+		pinned := make(map[string]bool, len(r.Tags))
+		for _, t := range r.Tags {
+			pinned[t] = true
+		}
+		for _, t := range tags {
+			if pinned[t] {
+				continue
+			}
+			if l.locked {
+				return fmt.Errorf("image repository %s tag %s: %w", repository, t, ErrNotPinned)
+			}
+			l.ImageRepositories[idx].Tags = append(l.ImageRepositories[idx].Tags, t)
+		}
+		return nil
+	}
+	if l.locked {
+		return fmt.Errorf("image repository %s: %w", repository, ErrNotPinned)
+	}
+
+	l.ImageRepositories = append(l.ImageRepositories, ImageRepository{Name: repository, Tags: append([]string{}, tags...)})
+	return nil
+}
+
+// PinImage records an image's digest, or verifies it against the pin when locked
+func (l *Lock) PinImage(name string, digest string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for idx, i := range l.Images {
+		if i.Name != name {
+			continue
+		}
+		if l.locked && i.Digest != digest {
+			return fmt.Errorf("image %s digest %s %w (%s)", name, digest, ErrMismatch, i.Digest)
+		}
+		l.Images[idx].Digest = digest
+		return nil
+	}
+	if l.locked {
+		return fmt.Errorf("image %s: %w", name, ErrNotPinned)
+	}
+
+	l.Images = append(l.Images, Image{Name: name, Digest: digest})
+	return nil
+}
+
+// ChartVersion returns the version to fetch for a chart, the exact pinned version when locked
+func (l *Lock) ChartVersion(c v1alpha1.Chart) (string, error) {
+	if !l.Locked() {
+		return c.Version, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	idx := l.chart(c, "")
+	if idx < 0 {
+		return "", fmt.Errorf("chart %s: %w", c.Name, ErrNotPinned)
+	}
+	return l.Charts[idx].Version, nil
+}
+
+// ChartVersions returns the exact versions pinned for a range of chart versions when locked, or nil otherwise
+func (l *Lock) ChartVersions(c v1alpha1.Chart) ([]string, error) {
+	if !l.Locked() {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var versions []string
+	for _, p := range l.Charts {
+		if p.Range && p.Name == c.Name && p.RepoURL == c.RepoURL && p.Constraint == c.Version {
+			versions = append(versions, p.Version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("chart %s versions %s: %w", c.Name, c.Version, ErrNotPinned)
+	}
+	return versions, nil
+}
+
+// PinChart records a chart's resolved version and digest, or verifies them against the pin when locked.  Each version
+// of a range of versions is pinned separately.
+func (l *Lock) PinChart(c v1alpha1.Chart, version string, digest string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if idx := l.chart(c, version); idx >= 0 {
+		p := l.Charts[idx]
+		if l.locked && (p.Version != version || p.Digest != digest) {
+			return fmt.Errorf("chart %s version %s digest %s %w (version %s digest %s)",
+				c.Name, version, digest, ErrMismatch, p.Version, p.Digest)
+		}
+		l.Charts[idx].Version = version
+		l.Charts[idx].Digest = digest
+		return nil
+	}
+	if l.locked {
+		return fmt.Errorf("chart %s: %w", c.Name, ErrNotPinned)
+	}
+
+	l.Charts = append(l.Charts, Chart{
+		Name:       c.Name,
+		RepoURL:    c.RepoURL,
+		Constraint: c.Version,
+		Range:      c.Versions == v1alpha1.ChartVersionsRange,
+		Version:    version,
+		Digest:     digest,
+	})
+	return nil
+}
+
+// chart returns the index of a chart's pin, ranges of versions are only matched by the given version
+func (l *Lock) chart(c v1alpha1.Chart, version string) int {
+	isRange := c.Versions == v1alpha1.ChartVersionsRange
+	for idx, p := range l.Charts {
+		if p.Name != c.Name || p.RepoURL != c.RepoURL || p.Constraint != c.Version || p.Range != isRange {
+			continue
+		}
+		if !isRange || p.Version == version {
+			return idx
+		}
+	}
+	return -1
+}
+
+// FileDigest returns the digest a file is pinned to, if any
+func (l *Lock) FileDigest(path string) (string, error) {
+	if !l.Locked() {
+		return "", nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, f := range l.Files {
+		if f.Path == path {
+			return f.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("file %s: %w", path, ErrNotPinned)
+}
+
+// PinFile records a file's digest, or verifies it against the pin when locked
+func (l *Lock) PinFile(path string, digest string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for idx, f := range l.Files {
+		if f.Path != path {
+			continue
+		}
+		if l.locked && f.Digest != digest {
+			return fmt.Errorf("file %s digest %s %w (%s)", path, digest, ErrMismatch, f.Digest)
+		}
+		l.Files[idx].Digest = digest
+		return nil
+	}
+	if l.locked {
+		return fmt.Errorf("file %s: %w", path, ErrNotPinned)
+	}
+
+	l.Files = append(l.Files, File{Path: path, Digest: digest})
+	return nil
+}
+
+// K3sVersion returns the exact k3s release a version or channel is pinned to when locked
+func (l *Lock) K3sVersion(version string) (string, error) {
+	if !l.Locked() {
+		return version, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, k := range l.K3s {
+		if k.Version == version {
+			return k.Resolved, nil
+		}
+	}
+	return "", fmt.Errorf("k3s %s: %w", version, ErrNotPinned)
+}
+
+// PinK3s records the release a k3s version or channel resolved to, or verifies it against the pin when locked
+func (l *Lock) PinK3s(version string, resolved string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for idx, k := range l.K3s {
+		if k.Version != version {
+			continue
+		}
+		if l.locked && k.Resolved != resolved {
+			return fmt.Errorf("k3s %s resolved to %s %w (%s)", version, resolved, ErrMismatch, k.Resolved)
+		}
+		l.K3s[idx].Resolved = resolved
+		return nil
+	}
+	if l.locked {
+		return fmt.Errorf("k3s %s: %w", version, ErrNotPinned)
+	}
+
+	l.K3s = append(l.K3s, K3s{Version: version, Resolved: resolved})
+	return nil
+}
+
+// RKE2Version returns the exact rke2 release a version or channel is pinned to when locked
+func (l *Lock) RKE2Version(version string) (string, error) {
+	if !l.Locked() {
+		return version, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, k := range l.RKE2 {
+		if k.Version == version {
+			return k.Resolved, nil
+		}
+	}
+	return "", fmt.Errorf("rke2 %s: %w", version, ErrNotPinned)
+}
+
+// PinRKE2 records the release an rke2 version or channel resolved to, or verifies it against the pin when locked
+func (l *Lock) PinRKE2(version string, resolved string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for idx, k := range l.RKE2 {
+		if k.Version != version {
+			continue
+		}
+		if l.locked && k.Resolved != resolved {
+			return fmt.Errorf("rke2 %s resolved to %s %w (%s)", version, resolved, ErrMismatch, k.Resolved)
+		}
+		l.RKE2[idx].Resolved = resolved
+		return nil
+	}
+	if l.locked {
+		return fmt.Errorf("rke2 %s: %w", version, ErrNotPinned)
+	}
+
+	l.RKE2 = append(l.RKE2, RKE2{Version: version, Resolved: resolved})
+	return nil
+}
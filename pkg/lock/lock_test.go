@@ -0,0 +1,216 @@
+package lock_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/lock"
+)
+
+func TestLock(t *testing.T) {
+	tmpdir, err := os.MkdirTemp("", "hauler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	chrt := v1alpha1.Chart{Name: "rancher", RepoURL: "https://releases.rancher.com/server-charts/latest", Version: ">=2.6.0"}
+
+	l := lock.New()
+	if err := l.PinImage("hello-world", "sha256:aaa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.PinChart(chrt, "2.6.3", "sha256:bbb"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.PinFile("https://get.k3s.io", "sha256:ccc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.PinK3s("stable", "v1.22.5+k3s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.PinRKE2("stable", "v1.22.5+rke2r1"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(tmpdir, lock.DefaultFilename)
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	locked, err := lock.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !locked.Locked() {
+		t.Fatal("expected loaded lock to be locked")
+	}
+
+	if v, err := locked.ChartVersion(chrt); err != nil || v != "2.6.3" {
+		t.Errorf("ChartVersion() = %s, %v, want 2.6.3", v, err)
+	}
+	if d, err := locked.FileDigest("https://get.k3s.io"); err != nil || d != "sha256:ccc" {
+		t.Errorf("FileDigest() = %s, %v, want sha256:ccc", d, err)
+	}
+	if v, err := locked.K3sVersion("stable"); err != nil || v != "v1.22.5+k3s1" {
+		t.Errorf("K3sVersion() = %s, %v, want v1.22.5+k3s1", v, err)
+	}
+	if v, err := locked.RKE2Version("stable"); err != nil || v != "v1.22.5+rke2r1" {
+		t.Errorf("RKE2Version() = %s, %v, want v1.22.5+rke2r1", v, err)
+	}
+
+	tests := []struct {
+		name    string
+		pin     func() error
+		wantErr error
+	}{
+		{
+			name:    "should accept a matching image",
+			pin:     func() error { return locked.PinImage("hello-world", "sha256:aaa") },
+			wantErr: nil,
+		},
+		{
+			name:    "should refuse a mismatched image",
+			pin:     func() error { return locked.PinImage("hello-world", "sha256:zzz") },
+			wantErr: lock.ErrMismatch,
+		},
+		{
+			name:    "should refuse an unpinned image",
+			pin:     func() error { return locked.PinImage("busybox", "sha256:aaa") },
+			wantErr: lock.ErrNotPinned,
+		},
+		{
+			name:    "should refuse a mismatched chart",
+			pin:     func() error { return locked.PinChart(chrt, "2.6.4", "sha256:bbb") },
+			wantErr: lock.ErrMismatch,
+		},
+		{
+			name:    "should refuse a mismatched file",
+			pin:     func() error { return locked.PinFile("https://get.k3s.io", "sha256:zzz") },
+			wantErr: lock.ErrMismatch,
+		},
+		{
+			name:    "should refuse an unpinned k3s version",
+			pin:     func() error { return locked.PinK3s("latest", "v1.23.1+k3s1") },
+			wantErr: lock.ErrNotPinned,
+		},
+		{
+			name:    "should refuse a mismatched rke2 version",
+			pin:     func() error { return locked.PinRKE2("stable", "v1.23.1+rke2r1") },
+			wantErr: lock.ErrMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pin()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLockChartRange(t *testing.T) {
+	chrt := v1alpha1.Chart{Name: "rancher", RepoURL: "https://releases.rancher.com/server-charts/latest", Version: ">=2.6.0 <2.7.0", Versions: v1alpha1.ChartVersionsRange}
+	single := chrt
+	single.Versions = ""
+
+	l := lock.New()
+	if vs, err := l.ChartVersions(chrt); err != nil || vs != nil {
+		t.Fatalf("ChartVersions() of an unlocked lock = %v, %v, want none", vs, err)
+	}
+	for _, v := range []string{"2.6.3", "2.6.2"} {
+		if err := l.PinChart(chrt, v, "sha256:"+v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.PinChart(single, "2.6.3", "sha256:2.6.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), lock.DefaultFilename)
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := lock.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vs, err := locked.ChartVersions(chrt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 2 || vs[0] != "2.6.3" || vs[1] != "2.6.2" {
+		t.Errorf("ChartVersions() = %v, want [2.6.3 2.6.2]", vs)
+	}
+	if v, err := locked.ChartVersion(single); err != nil || v != "2.6.3" {
+		t.Errorf("ChartVersion() = %s, %v, want 2.6.3", v, err)
+	}
+
+	if err := locked.PinChart(chrt, "2.6.2", "sha256:2.6.2"); err != nil {
+		t.Errorf("pin of a locked version of the range: %v", err)
+	}
+	if err := locked.PinChart(chrt, "2.6.2", "sha256:zzz"); !errors.Is(err, lock.ErrMismatch) {
+		t.Errorf("got error %v, want %v", err, lock.ErrMismatch)
+	}
+	if err := locked.PinChart(chrt, "2.6.4", "sha256:2.6.4"); !errors.Is(err, lock.ErrNotPinned) {
+		t.Errorf("got error %v, want %v", err, lock.ErrNotPinned)
+	}
+
+	other := chrt
+	other.Version = ">=2.7.0"
+	if _, err := locked.ChartVersions(other); !errors.Is(err, lock.ErrNotPinned) {
+		t.Errorf("got error %v, want %v", err, lock.ErrNotPinned)
+	}
+}
+
+func TestLockImageTags(t *testing.T) {
+	l := lock.New()
+	if tags, err := l.ImageTags("rancher/cowsay"); err != nil || tags != nil {
+		t.Fatalf("ImageTags() of an unlocked lock = %v, %v, want none", tags, err)
+	}
+	if err := l.PinImageTags("rancher/cowsay", []string{"v1.1.0", "v1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.PinImageTags("rancher/cowsay", []string{"v1.1.0", "latest"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Images declared on their own aren't tags selected from the repository
+	for _, name := range []string{"rancher/cowsay:v1.0.0", "rancher/cowsay:v9.9.9", "rancher/cowsay/extra:v1.0.0", "rancher/other:v1.0.0"} {
+		if err := l.PinImage(name, "sha256:aaa"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), lock.DefaultFilename)
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := lock.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := locked.ImageTags("rancher/cowsay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"v1.1.0", "v1.0.0", "latest"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("ImageTags() = %v, want %v", tags, want)
+	}
+	if _, err := locked.ImageTags("rancher/other"); !errors.Is(err, lock.ErrNotPinned) {
+		t.Errorf("got error %v, want %v", err, lock.ErrNotPinned)
+	}
+	if err := locked.PinImageTags("rancher/cowsay", []string{"v1.0.0"}); err != nil {
+		t.Errorf("PinImageTags() of a pinned tag error = %v", err)
+	}
+	if err := locked.PinImageTags("rancher/cowsay", []string{"v9.9.9"}); !errors.Is(err, lock.ErrNotPinned) {
+		t.Errorf("PinImageTags() of an unpinned tag error = %v, want %v", err, lock.ErrNotPinned)
+	}
+}
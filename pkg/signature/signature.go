@@ -0,0 +1,373 @@
+// Package signature verifies cosign signatures of images without depending on cosign itself.  Signatures are expected
+// in cosign's "simple signing" format, stored as an image tagged after the digest of the image they sign.
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rancherfederal/ocil/pkg/artifacts/image"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/content"
+)
+
+const (
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	SignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	CertificateAnnotation = "dev.sigstore.cosign/certificate"
+	ChainAnnotation       = "dev.sigstore.cosign/chain"
+	BundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+var (
+	ErrNoSignatures = errors.New("no signatures found")
+	ErrUnverified   = errors.New("no valid signature")
+)
+
+var (
+	// oidcIssuerV1 holds the raw issuer, oidcIssuerV2 holds it as a DER encoded string
+	oidcIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidcIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Ref returns the reference cosign stores the signatures of the image with digest d in repo at
+func Ref(repo name.Repository, d gv1.Hash) name.Tag {
+	return repo.Tag(fmt.Sprintf("%s-%s.sig", d.Algorithm, d.Hex))
+}
+
+// Verify verifies the signatures of the image in repo with digest d, pulled with the given remote options.  The image
+// is verified when any one of its signatures is valid, and the image holding its signatures is returned.
+func Verify(repo name.Repository, d gv1.Hash, cfg v1alpha1.ImageVerification, opts ...remote.Option) (*image.Image, error) {
+	v, err := newVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := Ref(repo, d)
+	sigs, err := content.NewImage(ref.Name(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s@%s: %w: %v", repo, d, ErrNoSignatures, err)
+	}
+
+	m, err := sigs.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var reasons []string
+	for _, desc := range m.Layers {
+		if desc.MediaType != SimpleSigningMediaType {
+			continue
+		}
+
+		l, err := sigs.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		rc, err := l.Compressed()
+		if err != nil {
+			return nil, err
+		}
+		payload, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := v.verify(d, payload, desc.Annotations); err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		return sigs, nil
+	}
+
+	if len(reasons) == 0 {
+		return nil, fmt.Errorf("%s@%s: %w", repo, d, ErrNoSignatures)
+	}
+	return nil, fmt.Errorf("%s@%s: %w: %s", repo, d, ErrUnverified, strings.Join(reasons, "; "))
+}
+
+type verifier struct {
+	key crypto.PublicKey
+
+	keyless  *v1alpha1.KeylessVerification
+	roots    *x509.CertPool
+	rekorKey crypto.PublicKey
+}
+
+func newVerifier(cfg v1alpha1.ImageVerification) (*verifier, error) {
+	switch {
+	case cfg.Key != "" && cfg.Keyless == nil:
+		key, err := loadPublicKey(cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+		return &verifier{key: key}, nil
+
+	case cfg.Key == "" && cfg.Keyless != nil:
+		k := cfg.Keyless
+		if k.Identity == "" || k.Issuer == "" || k.Roots == "" || k.RekorKey == "" {
+			return nil, fmt.Errorf("keyless verification requires an identity, issuer, roots and rekorKey")
+		}
+
+		data, err := os.ReadFile(k.Roots)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", k.Roots)
+		}
+
+		rekorKey, err := loadPublicKey(k.RekorKey)
+		if err != nil {
+			return nil, err
+		}
+		return &verifier{keyless: k, roots: roots, rekorKey: rekorKey}, nil
+
+	default:
+		return nil, fmt.Errorf("image verification requires exactly one of key or keyless")
+	}
+}
+
+// simpleSigning is the payload cosign signs
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+func (v *verifier) verify(d gv1.Hash, payload []byte, annotations map[string]string) error {
+	var ss simpleSigning
+	if err := json.Unmarshal(payload, &ss); err != nil {
+		return fmt.Errorf("parse signature payload: %w", err)
+	}
+	if ss.Critical.Image.DockerManifestDigest != d.String() {
+		return fmt.Errorf("signature is for %s", ss.Critical.Image.DockerManifestDigest)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(annotations[SignatureAnnotation])
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	if v.key != nil {
+		return verifySignature(v.key, payload, sig)
+	}
+
+	cert, err := v.verifyCertificate(annotations)
+	if err != nil {
+		return err
+	}
+	if err := v.verifyBundle(cert, payload, annotations); err != nil {
+		return err
+	}
+	return verifySignature(cert.PublicKey, payload, sig)
+}
+
+// verifyCertificate verifies a keyless signature's certificate was issued to the expected identity by the trusted roots
+func (v *verifier) verifyCertificate(annotations map[string]string) (*x509.Certificate, error) {
+	certs, err := parseCertificates([]byte(annotations[CertificateAnnotation]))
+	if err != nil || len(certs) == 0 {
+		return nil, fmt.Errorf("signature has no certificate")
+	}
+	cert := certs[0]
+
+	var identities []string
+	identities = append(identities, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+
+	var matched bool
+	for _, id := range identities {
+		if id == v.keyless.Identity {
+			matched = true
+		}
+	}
+	if !matched {
+		return nil, fmt.Errorf("certificate identity %v does not match %s", identities, v.keyless.Identity)
+	}
+
+	if issuer := certificateIssuer(cert); issuer != v.keyless.Issuer {
+		return nil, fmt.Errorf("certificate issuer %q does not match %s", issuer, v.keyless.Issuer)
+	}
+	return cert, nil
+}
+
+// rekorBundle is the transparency log entry cosign bundles with keyless signatures
+type rekorBundle struct {
+	SignedEntryTimestamp []byte
+	Payload              struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}
+}
+
+// hashedRekord is the transparency log entry body of a signature
+type hashedRekord struct {
+	Spec struct {
+		Signature struct {
+			Content string `json:"content"`
+		} `json:"signature"`
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+	} `json:"spec"`
+}
+
+// verifyBundle verifies a keyless signature was logged by the transparency log while its short-lived certificate was
+// valid, which is what allows the certificate to be trusted after it has expired
+func (v *verifier) verifyBundle(cert *x509.Certificate, payload []byte, annotations map[string]string) error {
+	data, ok := annotations[BundleAnnotation]
+	if !ok {
+		return fmt.Errorf("signature has no transparency log bundle")
+	}
+
+	var b rekorBundle
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return fmt.Errorf("parse transparency log bundle: %w", err)
+	}
+
+	// The payload's fields are in canonical order, and hold nothing that would be escaped differently
+	canonical, err := json.Marshal(b.Payload)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(v.rekorKey, canonical, b.SignedEntryTimestamp); err != nil {
+		return fmt.Errorf("transparency log bundle: %w", err)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(b.Payload.Body)
+	if err != nil {
+		return fmt.Errorf("decode transparency log entry: %w", err)
+	}
+	var entry hashedRekord
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return fmt.Errorf("parse transparency log entry: %w", err)
+	}
+
+	sum := sha256.Sum256(payload)
+	if entry.Spec.Signature.Content != annotations[SignatureAnnotation] || entry.Spec.Data.Hash.Value != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("transparency log entry is for another signature")
+	}
+
+	intermediates := x509.NewCertPool()
+	if chain, err := parseCertificates([]byte(annotations[ChainAnnotation])); err == nil {
+		for _, c := range chain {
+			intermediates.AddCert(c)
+		}
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Unix(b.Payload.IntegratedTime, 0),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("verify certificate: %w", err)
+	}
+	return nil
+}
+
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidcIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidcIssuerV1):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+func verifySignature(key crypto.PublicKey, payload []byte, sig []byte) error {
+	sum := sha256.Sum256(payload)
+
+	var ok bool
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(k, sum[:], sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, payload, sig)
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+
+	if !ok {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no public key found in %s", path)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(bytes.TrimSpace(data))
+		if block == nil {
+			break
+		}
+
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, nil
+}
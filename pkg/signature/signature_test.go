@@ -0,0 +1,350 @@
+package signature_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/rancherfederal/hauler/pkg/apis/hauler.cattle.io/v1alpha1"
+	"github.com/rancherfederal/hauler/pkg/signature"
+)
+
+const (
+	identity = "release@example.com"
+	issuer   = "https://issuer.example.com"
+)
+
+func TestVerify(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	dir := t.TempDir()
+
+	key := newKey(t)
+	otherKey := newKey(t)
+	rekorKey := newKey(t)
+	ca, caKey := newCA(t)
+
+	keyFile := writePEM(t, dir, "cosign.pub", publicKeyPEM(t, &key.PublicKey))
+	otherKeyFile := writePEM(t, dir, "other.pub", publicKeyPEM(t, &otherKey.PublicKey))
+	rekorKeyFile := writePEM(t, dir, "rekor.pub", publicKeyPEM(t, &rekorKey.PublicKey))
+	rootsFile := writePEM(t, dir, "roots.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+
+	signed := pushImage(t, host+"/library/signed:v1")
+	pushSignature(t, signed, signKey(t, key, payload(signed.digest)))
+
+	keyless := pushImage(t, host+"/library/keyless:v1")
+	pushSignature(t, keyless, signKeyless(t, ca, caKey, rekorKey, payload(keyless.digest)))
+
+	unsigned := pushImage(t, host+"/library/unsigned:v1")
+
+	// A valid signature copied from another image
+	copied := pushImage(t, host+"/library/copied:v1")
+	pushSignature(t, copied, signKey(t, key, payload(signed.digest)))
+
+	withKeyless := func(identity, issuer string) v1alpha1.ImageVerification {
+		return v1alpha1.ImageVerification{Keyless: &v1alpha1.KeylessVerification{
+			Identity: identity,
+			Issuer:   issuer,
+			Roots:    rootsFile,
+			RekorKey: rekorKeyFile,
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		img     pushed
+		cfg     v1alpha1.ImageVerification
+		wantErr error
+	}{
+		{
+			name: "signed with key",
+			img:  signed,
+			cfg:  v1alpha1.ImageVerification{Key: keyFile},
+		},
+		{
+			name:    "signed with another key",
+			img:     signed,
+			cfg:     v1alpha1.ImageVerification{Key: otherKeyFile},
+			wantErr: signature.ErrUnverified,
+		},
+		{
+			name:    "unsigned",
+			img:     unsigned,
+			cfg:     v1alpha1.ImageVerification{Key: keyFile},
+			wantErr: signature.ErrNoSignatures,
+		},
+		{
+			name:    "signature of another image",
+			img:     copied,
+			cfg:     v1alpha1.ImageVerification{Key: keyFile},
+			wantErr: signature.ErrUnverified,
+		},
+		{
+			name: "keyless",
+			img:  keyless,
+			cfg:  withKeyless(identity, issuer),
+		},
+		{
+			name:    "keyless with another identity",
+			img:     keyless,
+			cfg:     withKeyless("someone@example.com", issuer),
+			wantErr: signature.ErrUnverified,
+		},
+		{
+			name:    "keyless with another issuer",
+			img:     keyless,
+			cfg:     withKeyless(identity, "https://other.example.com"),
+			wantErr: signature.ErrUnverified,
+		},
+		{
+			name:    "keyless signature verified with a key",
+			img:     keyless,
+			cfg:     v1alpha1.ImageVerification{Key: keyFile},
+			wantErr: signature.ErrUnverified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sigs, err := signature.Verify(tt.img.repo, tt.img.digest, tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && sigs == nil {
+				t.Errorf("Verify() returned no signatures")
+			}
+		})
+	}
+
+	if _, err := signature.Verify(signed.repo, signed.digest, v1alpha1.ImageVerification{}); err == nil {
+		t.Errorf("Verify() without a key or keyless identity succeeded")
+	}
+}
+
+type pushed struct {
+	repo   name.Repository
+	digest gv1.Hash
+}
+
+type sig struct {
+	payload     []byte
+	annotations map[string]string
+}
+
+func pushImage(t *testing.T, ref string) pushed {
+	t.Helper()
+
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(r, img); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pushed{repo: r.Context(), digest: d}
+}
+
+func pushSignature(t *testing.T, img pushed, s sig) {
+	t.Helper()
+
+	l := static.NewLayer(s.payload, types.MediaType(signature.SimpleSigningMediaType))
+	sigs, err := mutate.Append(empty.Image, mutate.Addendum{Layer: l, Annotations: s.annotations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(signature.Ref(img.repo, img.digest), sigs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func payload(d gv1.Hash) []byte {
+	return []byte(`{"critical":{"identity":{"docker-reference":""},"image":{"docker-manifest-digest":"` + d.String() + `"},"type":"cosign container image signature"},"optional":null}`)
+}
+
+func signKey(t *testing.T, key *ecdsa.PrivateKey, payload []byte) sig {
+	return sig{
+		payload: payload,
+		annotations: map[string]string{
+			signature.SignatureAnnotation: sign(t, key, payload),
+		},
+	}
+}
+
+func signKeyless(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, rekorKey *ecdsa.PrivateKey, payload []byte) sig {
+	t.Helper()
+
+	key := newKey(t)
+	u, _ := url.Parse(issuer)
+
+	// The certificate expired long ago, it only has to be valid when the signature was logged
+	logged := time.Now().Add(-24 * time.Hour)
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		NotBefore:      logged.Add(-5 * time.Minute),
+		NotAfter:       logged.Add(5 * time.Minute),
+		EmailAddresses: []string{identity},
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{
+			{Id: []int{1, 3, 6, 1, 4, 1, 57264, 1, 1}, Value: []byte(u.String())},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	s := sign(t, key, payload)
+	sum := sha256.Sum256(payload)
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"signature": map[string]interface{}{"content": s, "publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(cert)}},
+			"data":      map[string]interface{}{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(sum[:])}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: logged.Unix(),
+		LogID:          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+		LogIndex:       42,
+	}
+	canonical, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := base64.StdEncoding.DecodeString(sign(t, rekorKey, canonical))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := json.Marshal(map[string]interface{}{
+		"SignedEntryTimestamp": set,
+		"Payload":              entry,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sig{
+		payload: payload,
+		annotations: map[string]string{
+			signature.SignatureAnnotation:   s,
+			signature.CertificateAnnotation: string(cert),
+			signature.BundleAnnotation:      string(bundle),
+		},
+	}
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) string {
+	t.Helper()
+
+	sum := sha256.Sum256(data)
+	s, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(s)
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key := newKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              time.Now().Add(48 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func writePEM(t *testing.T, dir string, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}